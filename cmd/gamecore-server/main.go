@@ -1,25 +1,29 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"gameCore/internal/bootstrap"
+	"gameCore/internal/storage"
 )
 
+// defaultShutdownTimeout используется, если app.shutdown_timeout не задан
+const defaultShutdownTimeout = 10 * time.Second
+
 func main() {
 	// Инициализируем сервисы
-	gameInstance, _, router := bootstrap.Init()
+	// Игровые комнаты запускаются менеджером по мере создания
+	cfg, _, wsServer, router := bootstrap.Init()
 
 	// Обслуживание статических файлов
 	// router.Static("/public", "./public")
 
-	// Запускаем игровые процессы
-	go gameInstance.Start()
-
 	// Настраиваем и запускаем HTTP сервер
 	server := &http.Server{
 		Addr:    ":8080",
@@ -39,10 +43,31 @@ func main() {
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
 	<-stop
 
-	// Грейсфул шатдаун
+	// Грейсфул шатдаун: всё должно уложиться в ShutdownTimeout
 	log.Println("Завершаем работу...")
-	// При необходимости добавьте shutdown логику
-	// wsServer.Shutdown(context.Background())
-	// server.Shutdown(context.Background())
+	timeout := cfg.App.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Перестаём принимать HTTP-запросы. WebSocket-соединения уже
+	// перехвачены у http.Server, их закрывает wsServer
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Ошибка остановки HTTP сервера: %v", err)
+	}
+
+	// Останавливаем комнаты, сохраняем итоги матчей и прощаемся с клиентами
+	if err := wsServer.Shutdown(ctx); err != nil {
+		log.Printf("Ошибка остановки WebSocket сервера: %v", err)
+	}
+
+	if err := storage.Close(); err != nil {
+		log.Printf("Ошибка закрытия базы данных: %v", err)
+	}
+	if err := storage.CloseRedis(); err != nil {
+		log.Printf("Ошибка закрытия Redis: %v", err)
+	}
 	log.Println("Сервер остановлен.")
 }
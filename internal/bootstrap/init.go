@@ -4,6 +4,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"gameCore/internal/auth"
@@ -19,7 +20,7 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func Init() (*game.Game, *network.WebSocketServer, *gin.Engine) {
+func Init() (*config.Config, *game.RoomManager, *network.WebSocketServer, *gin.Engine) {
 	cfg, err := config.LoadConfig("config/config.yaml")
 	if err != nil {
 		os.Exit(1)
@@ -46,8 +47,10 @@ func Init() (*game.Game, *network.WebSocketServer, *gin.Engine) {
 
 	// Repository initialization
 	userRepo := repository.NewUserRepo(storage.DB)
+	gameSessionRepo := repository.NewGameSessionRepo(storage.DB)
+	playerRepo := repository.NewPlayerRepo(storage.DB)
+	cheatFlagRepo := repository.NewCheatFlagRepo(storage.DB)
 	// leaderboardRepo := repository.NewLeaderboardRepo(storage.DB)
-	// playerRepo := repository.NewPlayerRepo(storage.DB)
 
 	// Redis initialization
 	if err := storage.InitRedis(cfg.Redis); err != nil {
@@ -59,23 +62,21 @@ func Init() (*game.Game, *network.WebSocketServer, *gin.Engine) {
 	authHandler := auth.NewAuthHandler(userRepo, cfg)
 	// WebSocket server
 
-	// Game core initialization
-	gameInstance := game.NewGame(
-	// game.WithPlayerRepo(playerRepo),
-	// game.WithLeaderboardRepo(leaderboardRepo),
-	)
+	// Game core initialization: комнаты создаются по запросу и матчмейкингом
+	roomManager := game.NewRoomManager(gameSessionRepo, playerRepo, cheatFlagRepo, cfg.Game)
 
-	wsServer := network.NewWebSocketServer(gameInstance, cfg.WebSocket)
+	wsServer := network.NewWebSocketServer(roomManager, cfg.WebSocket)
+	roomHandler := network.NewRoomHandler(roomManager)
 
 	// Router setup
 	router := gin.Default()
-	setupRoutes(router, authHandler, wsServer, cfg.JWT)
+	setupRoutes(router, authHandler, roomHandler, wsServer, cfg.JWT)
 
 	log.Info("Application initialization completed")
-	return gameInstance, wsServer, router
+	return cfg, roomManager, wsServer, router
 }
 
-func setupRoutes(router *gin.Engine, authHandler *auth.AuthHandler, wsServer *network.WebSocketServer, jwtSecret config.JWTConfig) {
+func setupRoutes(router *gin.Engine, authHandler *auth.AuthHandler, roomHandler *network.RoomHandler, wsServer *network.WebSocketServer, jwtSecret config.JWTConfig) {
 	// Настройка CORS middleware
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"}, // URL вашего фронтенда
@@ -99,6 +100,15 @@ func setupRoutes(router *gin.Engine, authHandler *auth.AuthHandler, wsServer *ne
 	authorized := api.Group("")
 	authorized.Use(middleware.AuthMiddleware(jwtSecret.SecretKey))
 	{
+		authorized.GET("/rooms", roomHandler.ListRoomsHandler)
+		authorized.POST("/rooms", roomHandler.CreateRoomHandler)
+		authorized.DELETE("/rooms/:id", roomHandler.DeleteRoomHandler)
+
+		// Счётчики защиты от флуда с запуска сервера
+		authorized.GET("/stats/flood", func(c *gin.Context) {
+			c.JSON(http.StatusOK, wsServer.FloodStats())
+		})
+
 		// Регистрируем WebSocket endpoint в защищенной группе
 		authorized.GET("/ws", func(c *gin.Context) {
 			userID, exists := c.Get("userID")
@@ -109,5 +119,16 @@ func setupRoutes(router *gin.Engine, authHandler *auth.AuthHandler, wsServer *ne
 
 			wsServer.HandleWS(c.Writer, c.Request, userID.(uint))
 		})
+
+		// Повтор записанного матча в темпе живой игры
+		authorized.GET("/replays/:session/ws", func(c *gin.Context) {
+			sessionID, err := strconv.ParseUint(c.Param("session"), 10, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session id"})
+				return
+			}
+
+			wsServer.HandleReplayWS(c.Writer, c.Request, uint(sessionID))
+		})
 	}
 }
@@ -56,21 +56,95 @@ type JWTConfig struct {
 }
 
 type WebSocketConfig struct {
-	ReadTimeout     time.Duration `yaml:"read_timeout"`
-	WriteTimeout    time.Duration `yaml:"write_timeout"`
-	MaxMessageSize  int           `yaml:"max_message_size"`
-	PongTimeout     time.Duration `yaml:"pong_timeout"`
-	PingInterval    time.Duration `yaml:"ping_interval"`
-	ReadBufferSize  int           `yaml:"read_buffer_size"`
-	WriteBufferSize int           `yaml:"write_buffer_size"`
+	ReadTimeout      time.Duration `yaml:"read_timeout"`
+	WriteTimeout     time.Duration `yaml:"write_timeout"`
+	MaxMessageSize   int           `yaml:"max_message_size"`
+	PongTimeout      time.Duration `yaml:"pong_timeout"`
+	PingInterval     time.Duration `yaml:"ping_interval"`
+	ReadBufferSize   int           `yaml:"read_buffer_size"`
+	WriteBufferSize  int           `yaml:"write_buffer_size"`
+	SendQueueSize    int           `yaml:"send_queue_size"`    // Ёмкость исходящей очереди соединения
+	DropPolicy       string        `yaml:"drop_policy"`        // drop_oldest | drop_newest
+	SendQueueTimeout time.Duration `yaml:"send_queue_timeout"` // Сколько очередь может быть полной до отключения
+	InputRate        float64       `yaml:"input_rate"`         // Сообщений движения в секунду от клиента
+	InputBurst       int           `yaml:"input_burst"`        // Запас сообщений движения сверх частоты
+	CommandRate      float64       `yaml:"command_rate"`       // Команд (улучшения, классы) в секунду
+	CommandBurst     int           `yaml:"command_burst"`      // Запас команд сверх частоты
+	FloodWarnAfter   int           `yaml:"flood_warn_after"`   // Отброшенных сообщений за 10 с до предупреждения
+	FloodKickAfter   int           `yaml:"flood_kick_after"`   // Отброшенных сообщений за 10 с до отключения
 }
 
 type GameConfig struct {
-	TickRate           time.Duration `yaml:"tick_rate"`
-	MaxPlayers         int           `yaml:"max_players"`
-	MatchTime          time.Duration `yaml:"match_time"`
-	RankRange          int           `yaml:"rank_range"`
-	RankExpandInterval time.Duration `yaml:"rank_expand_interval"`
+	TickRate            time.Duration      `yaml:"tick_rate"`
+	MaxPlayers          int                `yaml:"max_players"`
+	MatchTime           time.Duration      `yaml:"match_time"`
+	RankRange           int                `yaml:"rank_range"`
+	RankExpandInterval  time.Duration      `yaml:"rank_expand_interval"`
+	ViewRadius          float64            `yaml:"view_radius"`           // Радиус зоны интереса клиента
+	InterestCellSize    float64            `yaml:"interest_cell_size"`    // Размер ячейки сетки интереса
+	MaxRewind           time.Duration      `yaml:"max_rewind"`            // Предел отката при компенсации лага
+	Classes             []ClassConfig      `yaml:"classes"`               // Дерево специализаций, пусто - по умолчанию
+	Upgrades            []UpgradeConfig    `yaml:"upgrades"`              // Каталог улучшений, пусто - по умолчанию
+	ObjectKinds         []ObjectKindConfig `yaml:"object_kinds"`          // Виды объектов для фарма, пусто - по умолчанию
+	SpawnZones          []SpawnZoneConfig  `yaml:"spawn_zones"`           // Зоны появления объектов, пусто - вся карта
+	SpawnPlayerDistance float64            `yaml:"spawn_player_distance"` // Минимальное расстояние от игроков до нового объекта
+	SpawnObjectDistance float64            `yaml:"spawn_object_distance"` // Минимальный зазор между объектами
+	ReplayDir           string             `yaml:"replay_dir"`            // Каталог записей матчей, пусто - без записи
+	ReconnectGrace      time.Duration      `yaml:"reconnect_grace"`       // Сколько ждать переподключения игрока, отрицательное - не ждать
+	AntiCheat           AntiCheatConfig    `yaml:"anti_cheat"`
+}
+
+// AntiCheatConfig задаёт пороги очков подозрения античита
+type AntiCheatConfig struct {
+	FlagScore float64       `yaml:"flag_score"` // Очки, после которых аккаунт отмечается для проверки
+	KickScore float64       `yaml:"kick_score"` // Очки, после которых игрок отключается, отрицательное - не отключать
+	HalfLife  time.Duration `yaml:"half_life"`  // За сколько очки подозрения уменьшаются вдвое
+	// Сколько отключённый игрок не может вернуться, отрицательное - без ограничения
+	KickCooldown time.Duration `yaml:"kick_cooldown"`
+}
+
+// SpawnZoneConfig - прямоугольная область появления объектов
+type SpawnZoneConfig struct {
+	MinX   float64  `yaml:"min_x"`
+	MinY   float64  `yaml:"min_y"`
+	MaxX   float64  `yaml:"max_x"`
+	MaxY   float64  `yaml:"max_y"`
+	Weight float64  `yaml:"weight"` // Относительная частота выбора зоны
+	Kinds  []string `yaml:"kinds"`  // Виды объектов зоны, пусто - любые
+}
+
+// ObjectKindConfig описывает вид объекта для фарма опыта
+type ObjectKindConfig struct {
+	Name       string  `yaml:"name"`
+	Health     int     `yaml:"health"`
+	XP         int     `yaml:"xp"` // Опыт за уничтожение
+	Radius     float64 `yaml:"radius"`
+	Weight     float64 `yaml:"weight"`      // Относительная частота появления
+	MaxCount   int     `yaml:"max_count"`   // Предел объектов вида на карте, 0 - доля MaxObjects по весу
+	DriftSpeed float64 `yaml:"drift_speed"` // Скорость медленного дрейфа, пикселей в секунду
+}
+
+// UpgradeConfig описывает улучшение, которое игрок покупает за очко навыка.
+// Прибавка на уровне n равна Increment * Decay^n
+type UpgradeConfig struct {
+	Name        string   `yaml:"name"`         // Ключ улучшения в сообщении клиента
+	DisplayName string   `yaml:"display_name"` // Название для интерфейса
+	Stats       []string `yaml:"stats"`        // Статы, которые получают прибавку
+	Increment   float64  `yaml:"increment"`    // Прибавка на первом уровне
+	Decay       float64  `yaml:"decay"`        // Затухание прибавки, 0 - без затухания
+	MaxLevel    int      `yaml:"max_level"`
+}
+
+// ClassConfig описывает специализацию танка. Класс без родителя - корень дерева
+type ClassConfig struct {
+	Name         string             `yaml:"name"`
+	Parent       string             `yaml:"parent"`        // Класс, из которого открывается эта специализация
+	UnlockLevel  int                `yaml:"unlock_level"`  // Минимальный уровень для выбора
+	Barrels      int                `yaml:"barrels"`       // Число стволов
+	Spread       float64            `yaml:"spread"`        // Угол между соседними стволами, радианы
+	BulletRadius float64            `yaml:"bullet_radius"` // Радиус пули, 0 - по умолчанию
+	Multipliers  map[string]float64 `yaml:"multipliers"`   // Множители статов: damage, fire_rate, speed...
+	StatCaps     map[string]float64 `yaml:"stat_caps"`     // Предел стата для улучшений
 }
 
 type LoggingConfig struct {
@@ -0,0 +1,308 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gameCore/internal/config"
+)
+
+const (
+	DefaultFlagScore         = 50.0
+	DefaultKickScore         = 100.0
+	DefaultSuspicionHalfLife = time.Minute
+	DefaultKickCooldown      = 10 * time.Minute
+
+	snapAngle        = 2.0                    // Поворот прицела, радиан, который считается рывком
+	snapWindow       = 30 * time.Millisecond  // За сколько должен случиться рывок
+	snapShotWindow   = 50 * time.Millisecond  // Выстрел сразу после рывка - наводка ботом
+	pressSamples     = 16                     // Нажатий выстрела для анализа ритма
+	pressJitter      = 2 * time.Millisecond   // Разброс интервалов нажатий, недостижимый для человека
+	arrivalSamples   = 32                     // Нажатий клавиш и выстрела для анализа ритма
+	arrivalJitter    = 300 * time.Microsecond // Разброс интервалов сообщений, недостижимый по сети
+	arrivalMaxPeriod = 100 * time.Millisecond // Ритм реже этого не анализируем
+)
+
+// cheatReason - признак читерства, за который начисляются очки подозрения
+type cheatReason string
+
+const (
+	reasonInvalidInput cheatReason = "invalid_input" // Поле ввода вне допустимых значений
+	reasonReplayedSeq  cheatReason = "replayed_seq"  // Номер ввода не растёт
+	reasonAimSnap      cheatReason = "aim_snap"      // Мгновенный доворот прицела перед выстрелом
+	reasonFirePattern  cheatReason = "fire_pattern"  // Нажатия выстрела с машинной точностью
+	reasonInputTiming  cheatReason = "input_timing"  // Клавиши нажимаются с машинно ровным ритмом
+)
+
+var cheatWeights = map[cheatReason]float64{
+	reasonInvalidInput: 10,
+	reasonReplayedSeq:  2,
+	reasonAimSnap:      5,
+	reasonFirePattern:  20,
+	reasonInputTiming:  15,
+}
+
+// CheatReport описывает игрока, набравшего очки подозрения
+type CheatReport struct {
+	PlayerID uint
+	Score    float64
+	Reasons  map[string]int // Сколько раз сработал каждый признак
+	Kicked   bool
+}
+
+// ReasonList возвращает признаки в виде "aim_snap=3,fire_pattern=1"
+func (r CheatReport) ReasonList() string {
+	parts := make([]string, 0, len(r.Reasons))
+	for reason, count := range r.Reasons {
+		parts = append(parts, fmt.Sprintf("%s=%d", reason, count))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// suspicion - наблюдения за вводом одного игрока
+type suspicion struct {
+	score   float64
+	updated time.Time
+	reasons map[string]int
+	flagged bool
+
+	seen      bool // Уже было хотя бы одно сообщение
+	lastAt    time.Time
+	lastSeq   uint32
+	lastAngle float64
+	lastShoot bool
+	lastMove  [4]bool   // Вверх, вниз, влево, вправо
+	changedAt time.Time // Время последнего нажатия или отпускания клавиши
+	snapAt    time.Time // Время последнего рывка прицела
+	lastPress time.Time
+	presses   []time.Duration // Интервалы между нажатиями выстрела
+	arrivals  []time.Duration // Интервалы между нажатиями и отпусканиями
+}
+
+// antiCheat проверяет ввод до постановки в очередь комнаты. Работает в
+// читающих горутинах соединений и не трогает состояние симуляции, поэтому
+// детерминизм повтора не нарушается: в запись попадает уже очищенный ввод
+type antiCheat struct {
+	mu        sync.Mutex
+	players   map[uint]*suspicion
+	flagScore float64
+	kickScore float64
+	halfLife  time.Duration
+}
+
+func newAntiCheat() *antiCheat {
+	return &antiCheat{
+		players:   make(map[uint]*suspicion),
+		flagScore: DefaultFlagScore,
+		kickScore: DefaultKickScore,
+		halfLife:  DefaultSuspicionHalfLife,
+	}
+}
+
+// WithAntiCheat задаёт пороги античита. Нулевые поля оставляют значения по умолчанию
+func WithAntiCheat(cfg config.AntiCheatConfig) Option {
+	return func(g *Game) {
+		if cfg.FlagScore > 0 {
+			g.anticheat.flagScore = cfg.FlagScore
+		}
+		if cfg.KickScore != 0 {
+			g.anticheat.kickScore = cfg.KickScore
+		}
+		if cfg.HalfLife > 0 {
+			g.anticheat.halfLife = cfg.HalfLife
+		}
+	}
+}
+
+// WithCheatHandler задаёт обработчик игроков, которых нужно отметить для
+// проверки. Вызывается из читающей горутины соединения и не должен блокировать
+func WithCheatHandler(fn func(*Game, CheatReport)) Option {
+	return func(g *Game) {
+		g.onCheat = fn
+	}
+}
+
+// inspect очищает ввод и обновляет очки подозрения игрока. Возвращает
+// отчёт, если игрока пора отметить или отключить
+func (g *Game) inspect(input *PlayerInput, now time.Time) (CheatReport, bool) {
+	ac := g.anticheat
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	s, ok := ac.players[input.ID]
+	if !ok {
+		s = &suspicion{reasons: make(map[string]int), updated: now}
+		ac.players[input.ID] = s
+	}
+	ac.decay(s, now)
+
+	for _, reason := range g.sanitize(s, &input.Input) {
+		ac.add(s, reason)
+	}
+	for _, reason := range s.observe(input.Input, now) {
+		ac.add(s, reason)
+	}
+
+	kick := ac.kickScore > 0 && s.score >= ac.kickScore
+	if !kick && (s.flagged || s.score < ac.flagScore) {
+		return CheatReport{}, false
+	}
+	s.flagged = true
+
+	report := CheatReport{PlayerID: input.ID, Score: s.score, Reasons: make(map[string]int, len(s.reasons)), Kicked: kick}
+	for reason, count := range s.reasons {
+		report.Reasons[reason] = count
+	}
+	log.Printf("Античит: игрок %d набрал %.0f очков подозрения (%s)", input.ID, s.score, report.ReasonList())
+	return report, true
+}
+
+func (ac *antiCheat) add(s *suspicion, reason cheatReason) {
+	s.score += cheatWeights[reason]
+	s.reasons[string(reason)]++
+}
+
+// decay уменьшает очки вдвое за каждый halfLife: случайные срабатывания
+// честного игрока не копятся за долгий матч
+func (ac *antiCheat) decay(s *suspicion, now time.Time) {
+	elapsed := now.Sub(s.updated)
+	if elapsed > 0 {
+		s.score *= math.Exp2(-elapsed.Seconds() / ac.halfLife.Seconds())
+	}
+	s.updated = now
+}
+
+// restart начинает наблюдение за потоком ввода заново, сохраняя очки:
+// новое соединение нумерует ввод с нуля
+func (ac *antiCheat) restart(id uint) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if s, ok := ac.players[id]; ok {
+		ac.players[id] = &suspicion{score: s.score, updated: s.updated, reasons: s.reasons, flagged: s.flagged}
+	}
+}
+
+func (ac *antiCheat) forget(id uint) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	delete(ac.players, id)
+}
+
+// sanitize приводит поля ввода к допустимым значениям. Команды неизвестных
+// улучшений и классов выбрасываются, неконечный угол заменяется прежним
+func (g *Game) sanitize(s *suspicion, input *PlayerInputData) []cheatReason {
+	var reasons []cheatReason
+
+	if math.IsNaN(input.Angle) || math.IsInf(input.Angle, 0) {
+		input.Angle = s.lastAngle
+		reasons = append(reasons, reasonInvalidInput)
+	}
+	// Угол приводим к [-π, π], чтобы рывки считались по кратчайшей дуге
+	input.Angle = math.Remainder(input.Angle, 2*math.Pi)
+
+	if input.UpgradeStat != "" {
+		if _, ok := g.upgrades.byName[input.UpgradeStat]; !ok {
+			input.UpgradeStat = ""
+			reasons = append(reasons, reasonInvalidInput)
+		}
+	}
+	if input.Class != "" {
+		if _, ok := g.classes.classes[input.Class]; !ok {
+			input.Class = ""
+			reasons = append(reasons, reasonInvalidInput)
+		}
+	}
+	return reasons
+}
+
+// observe ищет в потоке ввода признаки бота. Каждый признак после
+// срабатывания начинает копить выборку заново
+func (s *suspicion) observe(input PlayerInputData, now time.Time) []cheatReason {
+	var reasons []cheatReason
+	move := [4]bool{input.Up, input.Down, input.Left, input.Right}
+	if !s.seen {
+		s.seen = true
+		s.lastAt, s.lastSeq, s.lastAngle, s.lastShoot = now, input.Seq, input.Angle, input.Shoot
+		s.lastMove, s.changedAt = move, now
+		return nil
+	}
+
+	if input.Seq != 0 && input.Seq <= s.lastSeq {
+		reasons = append(reasons, reasonReplayedSeq)
+	}
+
+	since := now.Sub(s.lastAt)
+	turn := math.Abs(math.Remainder(input.Angle-s.lastAngle, 2*math.Pi))
+	if turn > snapAngle && since < snapWindow {
+		s.snapAt = now
+	}
+
+	if input.Shoot && !s.lastShoot {
+		if !s.snapAt.IsZero() && now.Sub(s.snapAt) < snapShotWindow {
+			reasons = append(reasons, reasonAimSnap)
+			s.snapAt = time.Time{}
+		}
+		if !s.lastPress.IsZero() {
+			s.presses = appendSample(s.presses, now.Sub(s.lastPress), pressSamples)
+			if len(s.presses) == pressSamples && jitter(s.presses) < pressJitter {
+				reasons = append(reasons, reasonFirePattern)
+				s.presses = s.presses[:0]
+			}
+		}
+		s.lastPress = now
+	}
+
+	// Ритм считаем только по нажатиям клавиш и кнопки выстрела. Повтор
+	// клавиши ОС шлёт одинаковые сообщения с ровным периодом, а движение
+	// мыши браузер выравнивает по кадрам экрана, и на быстрой сети его
+	// ритм почти машинный у любого игрока
+	if move != s.lastMove || input.Shoot != s.lastShoot {
+		if changed := now.Sub(s.changedAt); changed < arrivalMaxPeriod {
+			s.arrivals = appendSample(s.arrivals, changed, arrivalSamples)
+			if len(s.arrivals) == arrivalSamples && jitter(s.arrivals) < arrivalJitter {
+				reasons = append(reasons, reasonInputTiming)
+				s.arrivals = s.arrivals[:0]
+			}
+		}
+		s.changedAt = now
+	}
+
+	s.lastAt, s.lastAngle, s.lastShoot, s.lastMove = now, input.Angle, input.Shoot, move
+	if input.Seq != 0 {
+		s.lastSeq = max(s.lastSeq, input.Seq)
+	}
+	return reasons
+}
+
+// appendSample добавляет значение в скользящее окно размера size
+func appendSample(samples []time.Duration, d time.Duration, size int) []time.Duration {
+	if len(samples) == size {
+		copy(samples, samples[1:])
+		samples = samples[:size-1]
+	}
+	return append(samples, d)
+}
+
+// jitter - стандартное отклонение интервалов
+func jitter(samples []time.Duration) time.Duration {
+	var mean float64
+	for _, d := range samples {
+		mean += float64(d)
+	}
+	mean /= float64(len(samples))
+
+	var variance float64
+	for _, d := range samples {
+		diff := float64(d) - mean
+		variance += diff * diff
+	}
+	return time.Duration(math.Sqrt(variance / float64(len(samples))))
+}
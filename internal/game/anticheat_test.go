@@ -0,0 +1,204 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+
+	"gameCore/internal/config"
+)
+
+// inputTrace - поток ввода одного клиента: каждое сообщение несёт полное
+// состояние ввода, как useInputHandler веб-клиента
+type inputTrace struct {
+	events []traceEvent
+}
+
+type traceEvent struct {
+	at     time.Duration
+	update func(*PlayerInputData)
+}
+
+func (tr *inputTrace) at(at time.Duration, update func(*PlayerInputData)) {
+	tr.events = append(tr.events, traceEvent{at: at, update: update})
+}
+
+// feed проводит поток через античит и возвращает сработавшие признаки
+func (tr *inputTrace) feed(g *Game) map[string]int {
+	sort.SliceStable(tr.events, func(i, j int) bool { return tr.events[i].at < tr.events[j].at })
+
+	start := time.Unix(0, 0)
+	var state PlayerInputData
+	for i, e := range tr.events {
+		e.update(&state)
+		state.Seq = uint32(i + 1)
+		input := PlayerInput{ID: 1, Input: state}
+		g.inspect(&input, start.Add(e.at))
+	}
+	return g.anticheat.players[1].reasons
+}
+
+// normal возвращает нормально распределённое отклонение со стандартным
+// отклонением sd
+func normal(rng *rand.Rand, sd time.Duration) time.Duration {
+	return time.Duration(rng.NormFloat64() * float64(sd))
+}
+
+// humanTrace моделирует пять минут игры человека на быстрой сети: мышь
+// ведёт цель с частотой кадров экрана, клавиши зажимаются с автоповтором
+// ОС, выстрелы идут сериями щелчков
+func humanTrace(seed int64) *inputTrace {
+	rng := rand.New(rand.NewSource(seed))
+	tr := &inputTrace{}
+	const length = 5 * time.Minute
+	const frame = time.Second / 60
+
+	// Движение мыши выровнено по кадрам, разброс доставки - доли миллисекунды
+	angle := 0.0
+	for t := time.Duration(0); t < length; {
+		moving := time.Duration(500+rng.Intn(2500)) * time.Millisecond
+		for end := t + moving; t < end; t += frame {
+			angle += rng.NormFloat64() * 0.05
+			a := angle
+			tr.at(t+normal(rng, 150*time.Microsecond), func(s *PlayerInputData) { s.Angle = a })
+		}
+		t += time.Duration(100+rng.Intn(900)) * time.Millisecond
+	}
+
+	// Клавиши: ОС повторяет зажатую клавишу с ровным периодом
+	keys := []func(*PlayerInputData, bool){
+		func(s *PlayerInputData, v bool) { s.Up = v },
+		func(s *PlayerInputData, v bool) { s.Down = v },
+		func(s *PlayerInputData, v bool) { s.Left = v },
+		func(s *PlayerInputData, v bool) { s.Right = v },
+	}
+	for t := time.Duration(0); t < length; {
+		key := keys[rng.Intn(len(keys))]
+		hold := time.Duration(150+rng.Intn(1500)) * time.Millisecond
+		tr.at(t, func(s *PlayerInputData) { key(s, true) })
+		for repeat := t + 500*time.Millisecond; repeat < t+hold; repeat += 33 * time.Millisecond {
+			tr.at(repeat+normal(rng, 100*time.Microsecond), func(s *PlayerInputData) { key(s, true) })
+		}
+		tr.at(t+hold, func(s *PlayerInputData) { key(s, false) })
+		t += hold + time.Duration(50+rng.Intn(400))*time.Millisecond
+	}
+
+	// Щелчки сериями: темп человека гуляет на десятки миллисекунд
+	for t := time.Duration(0); t < length; t += time.Duration(1+rng.Intn(4)) * time.Second {
+		for i := 0; i < 3+rng.Intn(12); i++ {
+			tr.at(t, func(s *PlayerInputData) { s.Shoot = true })
+			tr.at(t+80*time.Millisecond+normal(rng, 15*time.Millisecond), func(s *PlayerInputData) { s.Shoot = false })
+			t += 220*time.Millisecond + normal(rng, 40*time.Millisecond)
+		}
+	}
+	return tr
+}
+
+// Живой игрок на быстрой сети не набирает очков подозрения
+func TestHumanInputNotFlagged(t *testing.T) {
+	for seed := int64(1); seed <= 5; seed++ {
+		g := NewGame(WithSeed(1))
+		if reasons := humanTrace(seed).feed(g); len(reasons) != 0 {
+			t.Fatalf("семя %d: у человека сработали признаки %v", seed, reasons)
+		}
+	}
+}
+
+// Машинный ритм и наводка ботом распознаются
+func TestBotInputFlagged(t *testing.T) {
+	tests := []struct {
+		name   string
+		trace  func() *inputTrace
+		reason cheatReason
+	}{
+		{"автокликер", func() *inputTrace {
+			tr := &inputTrace{}
+			for i := 0; i < 40; i++ {
+				at := time.Duration(i) * 100 * time.Millisecond
+				tr.at(at, func(s *PlayerInputData) { s.Shoot = true })
+				tr.at(at+50*time.Millisecond, func(s *PlayerInputData) { s.Shoot = false })
+			}
+			return tr
+		}, reasonFirePattern},
+		{"бот жмёт клавиши по таймеру", func() *inputTrace {
+			tr := &inputTrace{}
+			for i := 0; i < 40; i++ {
+				up := i%2 == 0
+				tr.at(time.Duration(i)*50*time.Millisecond, func(s *PlayerInputData) { s.Up = up })
+			}
+			return tr
+		}, reasonInputTiming},
+		{"доворот прицела и выстрел", func() *inputTrace {
+			tr := &inputTrace{}
+			tr.at(0, func(s *PlayerInputData) { s.Angle = 0 })
+			tr.at(10*time.Millisecond, func(s *PlayerInputData) { s.Angle = 3 })
+			tr.at(20*time.Millisecond, func(s *PlayerInputData) { s.Shoot = true })
+			return tr
+		}, reasonAimSnap},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGame(WithSeed(1))
+			if reasons := tt.trace().feed(g); reasons[string(tt.reason)] == 0 {
+				t.Fatalf("признак %s не сработал: %v", tt.reason, reasons)
+			}
+		})
+	}
+}
+
+// Пороги отметки и отключения считаются по весам признаков
+func TestSuspicionThresholds(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.AntiCheatConfig
+		invalid int // Сообщений с неконечным углом, по 10 очков
+		report  bool
+		kicked  bool
+	}{
+		{"ниже порога", config.AntiCheatConfig{}, 4, false, false},
+		{"отметка", config.AntiCheatConfig{}, 5, true, false},
+		{"отключение", config.AntiCheatConfig{}, 10, true, true},
+		{"отключение выключено", config.AntiCheatConfig{KickScore: -1}, 20, true, false},
+		{"свой порог отметки", config.AntiCheatConfig{FlagScore: 20}, 2, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGame(WithSeed(1), WithAntiCheat(tt.cfg))
+			now := time.Unix(0, 0)
+			var report CheatReport
+			reported := false
+			for i := 0; i < tt.invalid; i++ {
+				input := PlayerInput{ID: 1, Input: PlayerInputData{Angle: math.NaN(), Seq: uint32(i + 1)}}
+				if r, ok := g.inspect(&input, now); ok {
+					report, reported = r, true
+				}
+				if math.IsNaN(input.Input.Angle) {
+					t.Fatal("неконечный угол не заменён")
+				}
+			}
+			if reported != tt.report || report.Kicked != tt.kicked {
+				t.Fatalf("отчёт %v, отключение %v; ожидалось %v, %v", reported, report.Kicked, tt.report, tt.kicked)
+			}
+		})
+	}
+}
+
+// Очки подозрения убывают вдвое за каждый HalfLife
+func TestSuspicionDecay(t *testing.T) {
+	g := NewGame(WithSeed(1))
+	now := time.Unix(0, 0)
+	for i := 0; i < 4; i++ {
+		input := PlayerInput{ID: 1, Input: PlayerInputData{Angle: math.Inf(1), Seq: uint32(i + 1)}}
+		g.inspect(&input, now)
+	}
+
+	s := g.anticheat.players[1]
+	g.anticheat.decay(s, now.Add(2*DefaultSuspicionHalfLife))
+	if math.Abs(s.score-10) > 1e-9 {
+		t.Fatalf("через два периода полураспада очков %v, ожидалось 10", s.score)
+	}
+}
@@ -0,0 +1,43 @@
+package game
+
+import (
+	"log"
+
+	"gameCore/internal/protocol"
+)
+
+// chatMessage - нагрузка protocol.TypeChat
+type chatMessage struct {
+	PlayerID uint   `json:"player_id"`
+	Text     string `json:"text"`
+}
+
+func (m chatMessage) MarshalBinaryTo(w *protocol.Writer) {
+	w.Uint32(uint32(m.PlayerID))
+	w.String(m.Text)
+}
+
+// Chat рассылает сообщение игрока id всем игрокам и зрителям комнаты.
+// Чат не влияет на симуляцию и не попадает в запись матча
+func (g *Game) Chat(id uint, text string) error {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+
+	if player, exists := g.Players[id]; !exists || player.disconnected {
+		return ErrPlayerNotFound
+	}
+
+	message := chatMessage{PlayerID: id, Text: text}
+	for _, player := range g.Players {
+		g.sendToPlayer(player, protocol.TypeChat, message)
+	}
+	for _, spectator := range g.Spectators {
+		if spectator.Conn == nil {
+			continue
+		}
+		if err := spectator.Conn.Send(protocol.TypeChat, message); err != nil {
+			log.Printf("Ошибка отправки %s зрителю %d: %v", protocol.TypeChat, spectator.ID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"gameCore/internal/config"
+)
+
+// DefaultClasses - дерево специализаций по умолчанию
+var DefaultClasses = []config.ClassConfig{
+	{Name: "basic", UnlockLevel: 1, Barrels: 1},
+	{
+		Name: "twin", Parent: "basic", UnlockLevel: 15, Barrels: 2, Spread: 0.1,
+		Multipliers: map[string]float64{"damage": 0.75},
+	},
+	{
+		Name: "sniper", Parent: "basic", UnlockLevel: 15, Barrels: 1,
+		Multipliers: map[string]float64{"damage": 1.5, "bullet_speed": 1.5, "fire_rate": 0.6},
+		StatCaps:    map[string]float64{"fire_rate": 4},
+	},
+	{
+		Name: "machine_gun", Parent: "basic", UnlockLevel: 15, Barrels: 1, BulletRadius: 2,
+		Multipliers: map[string]float64{"damage": 0.7, "fire_rate": 2},
+		StatCaps:    map[string]float64{"damage": 70},
+	},
+	{
+		Name: "triple_shot", Parent: "twin", UnlockLevel: 30, Barrels: 3, Spread: 0.3,
+		Multipliers: map[string]float64{"damage": 0.7},
+	},
+	{
+		Name: "destroyer", Parent: "machine_gun", UnlockLevel: 30, Barrels: 1, BulletRadius: 8,
+		Multipliers: map[string]float64{"damage": 3, "fire_rate": 0.3, "bullet_speed": 0.7},
+		StatCaps:    map[string]float64{"fire_rate": 3},
+	},
+}
+
+// tankClass - узел дерева специализаций
+type tankClass struct {
+	config.ClassConfig
+	children []*tankClass
+}
+
+// classTree хранит специализации по имени и корневой класс новых игроков
+type classTree struct {
+	root    *tankClass
+	classes map[string]*tankClass
+}
+
+func newClassTree(defs []config.ClassConfig) (*classTree, error) {
+	tree := &classTree{classes: make(map[string]*tankClass, len(defs))}
+
+	for _, def := range defs {
+		if def.Name == "" {
+			return nil, fmt.Errorf("%w: класс без имени", ErrInvalidClassTree)
+		}
+		if _, exists := tree.classes[def.Name]; exists {
+			return nil, fmt.Errorf("%w: класс %s объявлен дважды", ErrInvalidClassTree, def.Name)
+		}
+		if def.Barrels <= 0 {
+			def.Barrels = 1
+		}
+		if def.BulletRadius <= 0 {
+			def.BulletRadius = BulletRadius
+		}
+		tree.classes[def.Name] = &tankClass{ClassConfig: def}
+	}
+
+	for _, def := range defs {
+		class := tree.classes[def.Name]
+		if def.Parent == "" {
+			if tree.root != nil {
+				return nil, fmt.Errorf("%w: несколько корневых классов", ErrInvalidClassTree)
+			}
+			tree.root = class
+			continue
+		}
+		parent, exists := tree.classes[def.Parent]
+		if !exists {
+			return nil, fmt.Errorf("%w: у класса %s нет родителя %s", ErrInvalidClassTree, def.Name, def.Parent)
+		}
+		parent.children = append(parent.children, class)
+	}
+
+	if tree.root == nil {
+		return nil, fmt.Errorf("%w: нет корневого класса", ErrInvalidClassTree)
+	}
+	return tree, nil
+}
+
+// WithClasses задаёт дерево специализаций комнаты. Некорректное дерево
+// отбрасывается, остаётся дерево по умолчанию
+func WithClasses(defs []config.ClassConfig) Option {
+	return func(g *Game) {
+		if len(defs) == 0 {
+			return
+		}
+		tree, err := newClassTree(defs)
+		if err != nil {
+			log.Printf("Дерево классов комнаты %s не загружено: %v", g.ID, err)
+			return
+		}
+		g.classes = tree
+	}
+}
+
+// unlocked возвращает специализации, доступные из класса на уровне level
+func (c *tankClass) unlocked(level int) []string {
+	var names []string
+	for _, child := range c.children {
+		if level >= child.UnlockLevel {
+			names = append(names, child.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// barrelAngles раскладывает стволы веером вокруг направления angle
+func (c *tankClass) barrelAngles(angle float64) []float64 {
+	angles := make([]float64, c.Barrels)
+	center := float64(c.Barrels-1) / 2
+	for i := range angles {
+		angles[i] = angle + (float64(i)-center)*c.Spread
+	}
+	return angles
+}
+
+// chooseClass переводит игрока в дочерний класс его текущей специализации.
+// Вызывается под g.Mutex
+func (g *Game) chooseClass(player *Player, name string) error {
+	class, err := g.classAllowed(player, name)
+	if err != nil {
+		return err
+	}
+
+	player.class = class
+	// Статы, прокачанные выше пределов нового класса, срезаются
+	for key, limit := range class.StatCaps {
+		if player.Stats[key] > limit {
+			player.Stats[key] = limit
+		}
+	}
+
+	log.Printf("Игрок %d выбрал класс %s", player.ID, name)
+	return nil
+}
+
+// classAllowed проверяет, может ли игрок перейти в класс name. Вызывается под g.Mutex
+func (g *Game) classAllowed(player *Player, name string) (*tankClass, error) {
+	class, exists := g.classes.classes[name]
+	if !exists {
+		return nil, ErrUnknownClass
+	}
+	if class.Parent != player.class.Name || player.Level < class.UnlockLevel {
+		return nil, ErrClassLocked
+	}
+	return class, nil
+}
+
+// CanChooseClass сообщает, примет ли симуляция выбор класса игроком.
+// Выбор текущего класса ничего не меняет и допускается
+func (g *Game) CanChooseClass(id uint, name string) error {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+
+	player, exists := g.Players[id]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+	if name == player.class.Name {
+		return nil
+	}
+	_, err := g.classAllowed(player, name)
+	return err
+}
+
+// stat возвращает значение стата с учётом множителя класса
+func (p *Player) stat(key string) float64 {
+	value := p.Stats[key]
+	if multiplier, ok := p.class.Multipliers[key]; ok {
+		value *= multiplier
+	}
+	return value
+}
+
+// atCap сообщает, что стат уже достиг предела текущего класса
+func (p *Player) atCap(key string) bool {
+	limit, ok := p.class.StatCaps[key]
+	return ok && p.Stats[key] >= limit
+}
+
+// ClassName возвращает имя текущей специализации игрока
+func (p *Player) ClassName() string {
+	return p.class.Name
+}
+
+func mustClassTree(defs []config.ClassConfig) *classTree {
+	tree, err := newClassTree(defs)
+	if err != nil {
+		panic(err)
+	}
+	return tree
+}
+
+var defaultClassTree = mustClassTree(DefaultClasses)
+
+// HasClass сообщает, есть ли специализация name в дереве классов комнаты
+func (g *Game) HasClass(name string) bool {
+	_, ok := g.classes.classes[name]
+	return ok
+}
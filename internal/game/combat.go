@@ -3,55 +3,238 @@ package game
 import (
 	"log"
 	"math"
-	"math/rand"
-	"time"
+
+	"gameCore/internal/protocol"
 )
 
 // TakeDamage обрабатывает получение урона игроком
 func (p *Player) TakeDamage(damage float64, game *Game, attackerID uint) {
+	p.takeDamage(damage, game, attackerID, true)
+}
+
+// takeBodyDamage наносит таранный урон. Он приходит каждый шаг контакта,
+// поэтому в лог не пишется
+func (p *Player) takeBodyDamage(damage float64, game *Game, attackerID uint) {
+	p.takeDamage(damage, game, attackerID, false)
+}
+
+func (p *Player) takeDamage(damage float64, game *Game, attackerID uint, logged bool) {
+	if p.protected {
+		return
+	}
+
 	p.Stats["health"] -= damage
-	log.Printf("Игрок %d получил %.1f урона от %d. Осталось здоровья: %.1f",
-		p.ID, damage, attackerID, p.Stats["health"])
+	if logged {
+		log.Printf("Игрок %d получил %.1f урона от %d. Осталось здоровья: %.1f",
+			p.ID, damage, attackerID, p.Stats["health"])
+	}
+	game.emit(Event{Kind: EventDamageDealt, SourceID: attackerID, TargetID: p.ID, X: p.X, Y: p.Y, Amount: damage})
 
 	if p.Stats["health"] <= 0 {
 		p.Die(game, attackerID)
 	}
 }
 
-// checkBulletCollisions проверяет коллизии пули с игроками
-func (g *Game) checkBulletCollisions(bullet *Bullet) bool {
-	g.Mutex.RLock()
-	defer g.Mutex.RUnlock()
+// rebuildCollisionGrids раскладывает живых игроков и активные объекты по сетке
+func (g *Game) rebuildCollisionGrids() {
+	g.rebuildPlayerGrid()
+
+	g.objectGrid.Clear()
+	for _, obj := range g.Objects {
+		if obj.Active {
+			g.objectGrid.Insert(obj.X, obj.Y, obj)
+		}
+	}
+}
+
+func (g *Game) rebuildPlayerGrid() {
+	g.playerGrid.Clear()
+	for _, player := range g.playerOrder {
+		if player.Alive {
+			g.playerGrid.Insert(player.X, player.Y, player)
+		}
+	}
+}
+
+// resolveBodyCollisions расталкивает пересекающихся игроков, а игроков -
+// от объектов, и наносит таранный урон по стату body_damage (урон в секунду
+// контакта). Дрейф объектов слишком медленный, поэтому сдвигается только игрок.
+// Вызывается из шага симуляции после перестроения сеток
+func (g *Game) resolveBodyCollisions(dt float64) {
+	moved := false
+
+	for _, player := range g.playerOrder {
+		if !player.Alive {
+			continue
+		}
+
+		reach := 2 * PlayerRadius
+		g.playerGrid.Query(player.X, player.Y, reach, func(_, _ float64, other *Player) {
+			// Каждую пару обрабатываем один раз
+			if other.ID <= player.ID || !other.Alive || !player.Alive {
+				return
+			}
+			nx, ny, overlap, ok := circleOverlap(player.X, player.Y, other.X, other.Y, reach)
+			if !ok {
+				return
+			}
+
+			player.X -= nx * overlap / 2
+			player.Y -= ny * overlap / 2
+			other.X += nx * overlap / 2
+			other.Y += ny * overlap / 2
+			clampToWorld(player)
+			clampToWorld(other)
+			moved = true
+
+			playerDamage := player.stat("body_damage") * dt
+			otherDamage := other.stat("body_damage") * dt
+			if playerDamage > 0 {
+				other.takeBodyDamage(playerDamage, g, player.ID)
+			}
+			if otherDamage > 0 && player.Alive {
+				player.takeBodyDamage(otherDamage, g, other.ID)
+			}
+		})
+		if !player.Alive {
+			continue
+		}
+
+		g.objectGrid.Query(player.X, player.Y, PlayerRadius+g.objectKinds.maxRadius, func(_, _ float64, obj *Object) {
+			if !obj.Active {
+				return
+			}
+			nx, ny, overlap, ok := circleOverlap(obj.X, obj.Y, player.X, player.Y, PlayerRadius+obj.Radius)
+			if !ok {
+				return
+			}
+
+			player.X += nx * overlap
+			player.Y += ny * overlap
+			clampToWorld(player)
+			moved = true
+
+			if damage := player.stat("body_damage") * dt; damage > 0 {
+				obj.ObjectTakeDamage(damage, g, player.ID)
+			}
+		})
+	}
+
+	// Сдвинутые игроки должны попасть в нужные ячейки до проверки пуль
+	if moved {
+		g.rebuildPlayerGrid()
+	}
+}
+
+// circleOverlap возвращает единичный вектор от (x0, y0) к (x1, y1) и глубину
+// пересечения окружностей с суммой радиусов reach
+func circleOverlap(x0, y0, x1, y1, reach float64) (nx, ny, overlap float64, ok bool) {
+	dx, dy := x1-x0, y1-y0
+	dist := math.Hypot(dx, dy)
+	if dist >= reach {
+		return 0, 0, 0, false
+	}
+	if dist == 0 {
+		// Центры совпали - расталкиваем по произвольной оси
+		return 1, 0, reach, true
+	}
+	return dx / dist, dy / dist, reach - dist, true
+}
+
+func clampToWorld(player *Player) {
+	player.X = math.Max(MinX, math.Min(MaxX, player.X))
+	player.Y = math.Max(MinY, math.Min(MaxY, player.Y))
+}
+
+// checkBulletCollisions ищет первое попадание на отрезке, который пуля прошла
+// за шаг, поэтому быстрая пуля не проскакивает игрока между тиками.
+// Кандидаты берутся из сеток коллизий. Возвращает false, если пуля попала.
+// Вызывается из шага симуляции, который уже держит g.Mutex
+func (g *Game) checkBulletCollisions(bullet *Bullet, fromX, fromY float64) bool {
+	minX, maxX := math.Min(fromX, bullet.X), math.Max(fromX, bullet.X)
+	minY, maxY := math.Min(fromY, bullet.Y), math.Max(fromY, bullet.Y)
+
+	hitT := math.Inf(1)
+	var hitPlayer *Player
+	var hitObject *Object
 
-	for _, player := range g.Players {
+	reach := PlayerRadius + bullet.Radius
+	g.playerGrid.QueryRect(minX-reach, minY-reach, maxX+reach, maxY+reach, func(x, y float64, player *Player) {
 		// Пуля не может попасть в своего владельца или мертвого игрока
 		if player.ID == bullet.OwnerID || !player.Alive {
-			continue
+			return
+		}
+		if t, ok := segmentCircleHit(fromX, fromY, bullet.X, bullet.Y, x, y, reach); ok && t < hitT {
+			hitT, hitPlayer, hitObject = t, player, nil
 		}
+	})
 
-		if g.isColliding(bullet, player) {
-			player.TakeDamage(bullet.Damage, g, bullet.OwnerID)
-			bullet.Active = false
-			return false
+	reach = g.objectKinds.maxRadius + bullet.Radius
+	g.objectGrid.QueryRect(minX-reach, minY-reach, maxX+reach, maxY+reach, func(x, y float64, obj *Object) {
+		if !obj.Active {
+			return
 		}
+		if t, ok := segmentCircleHit(fromX, fromY, bullet.X, bullet.Y, x, y, obj.Radius+bullet.Radius); ok && t < hitT {
+			hitT, hitPlayer, hitObject = t, nil, obj
+		}
+	})
+
+	if hitPlayer == nil && hitObject == nil {
+		return true
 	}
-	return true
+
+	// Пуля останавливается в точке попадания
+	bullet.X = fromX + (bullet.X-fromX)*hitT
+	bullet.Y = fromY + (bullet.Y-fromY)*hitT
+	bullet.Active = false
+
+	if hitPlayer != nil {
+		hitPlayer.TakeDamage(bullet.Damage, g, bullet.OwnerID)
+	} else {
+		hitObject.ObjectTakeDamage(bullet.Damage, g, bullet.OwnerID)
+	}
+	return false
 }
 
-func (g *Game) isColliding(bullet *Bullet, player *Player) bool {
-	dx := bullet.X - player.X
-	dy := bullet.Y - player.Y
-	distance := math.Sqrt(dx*dx + dy*dy)
-	return distance < (PlayerRadius + BulletRadius)
+// segmentCircleHit возвращает долю пути t в [0, 1], на которой отрезок
+// (x0, y0)-(x1, y1) впервые касается окружности (cx, cy, r)
+func segmentCircleHit(x0, y0, x1, y1, cx, cy, r float64) (float64, bool) {
+	dx, dy := x1-x0, y1-y0
+	fx, fy := x0-cx, y0-cy
+
+	c := fx*fx + fy*fy - r*r
+	if c <= 0 {
+		// Отрезок начинается внутри окружности
+		return 0, true
+	}
+
+	a := dx*dx + dy*dy
+	if a == 0 {
+		return 0, false
+	}
+
+	b := 2 * (fx*dx + fy*dy)
+	disc := b*b - 4*a*c
+	if disc < 0 {
+		return 0, false
+	}
+
+	t := (-b - math.Sqrt(disc)) / (2 * a)
+	if t < 0 || t > 1 {
+		return 0, false
+	}
+	return t, true
 }
 
 func (p *Player) Die(game *Game, killerID uint) {
 	p.Alive = false
+	p.diedTick = game.Tick
 	log.Printf("Игрок %d убит игроком %d", p.ID, killerID)
+	game.emit(Event{Kind: EventPlayerKilled, SourceID: killerID, TargetID: p.ID, X: p.X, Y: p.Y})
 
-	// Запускаем таймер респавна
-	p.RespawnTimer = time.AfterFunc(RespawnTime, func() {
-		game.RespawnPlayer(p.ID)
+	// Респавн выполнится в шаге симуляции и отменится, если игрок выйдет
+	game.after(RespawnTime, playerOwner(p.ID), func() {
+		game.respawnPlayer(p)
 	})
 
 	// Обрабатываем убийство
@@ -63,14 +246,29 @@ func (g *Game) RespawnPlayer(playerID uint) {
 	defer g.Mutex.Unlock()
 
 	if player, exists := g.Players[playerID]; exists {
-		player.Alive = true
-		player.Stats["health"] = BasePlayerHealth
-		player.X = float64(rand.Intn(MaxX))
-		player.Y = float64(rand.Intn(MaxY))
-		log.Printf("Игрок %d возродился", playerID)
+		g.respawnPlayer(player)
 	}
 }
 
+// respawnPlayer вызывается под g.Mutex
+func (g *Game) respawnPlayer(player *Player) {
+	player.Alive = true
+	player.Stats["health"] = BasePlayerHealth
+	player.X = float64(g.rng.Intn(MaxX))
+	player.Y = float64(g.rng.Intn(MaxY))
+	g.protectPlayer(player)
+	log.Printf("Игрок %d возродился", player.ID)
+	g.emit(Event{Kind: EventRespawned, TargetID: player.ID, X: player.X, Y: player.Y})
+}
+
+// protectPlayer даёт временную неуязвимость после появления на карте
+func (g *Game) protectPlayer(player *Player) {
+	player.protected = true
+	g.after(SpawnProtection, playerOwner(player.ID), func() {
+		player.protected = false
+	})
+}
+
 func (g *Game) HandleKill(killerID, victimID uint) {
 	killer, kExists := g.Players[killerID]
 	victim, vExists := g.Players[victimID]
@@ -85,7 +283,7 @@ func (g *Game) HandleKill(killerID, victimID uint) {
 		victim.XP -= lostXP
 	}
 	if gainedXP > 0 {
-		killer.GainXP(gainedXP)
+		g.awardXP(killer, gainedXP)
 	}
 	log.Printf("Игрок %d убил игрока %d", killerID, victimID)
 }
@@ -109,72 +307,18 @@ func (g *Player) CheckLvlUp() {
 			g.ID, g.Level, g.SkillPoints)
 		g.NewLvlExp = g.Level * g.Level * 100
 	}
-	if leveledUp {
-		// Отправляем обновлённые данные игроку
-		g.Conn.WriteJSON(map[string]interface{}{
-			"skill_points": g.SkillPoints,
-			"level":        g.Level,
-			"new_lvl_exp":  g.NewLvlExp,
+	if leveledUp && g.Conn != nil {
+		// Отправляем обновлённые данные игроку вместе с открывшимися классами
+		err := g.Conn.Send(protocol.TypeLevelUp, levelUpMessage{
+			SkillPoints: g.SkillPoints,
+			Level:       g.Level,
+			NewLvlExp:   g.NewLvlExp,
+			Classes:     g.class.unlocked(g.Level),
 		})
-	}
-
-	log.Printf("Кап до нового уровня: %d", (g.NewLvlExp))
-}
-
-func (g *Game) handleUpgrade(playerID uint, stat string) {
-	player, exists := g.Players[playerID]
-	if !exists || player.SkillPoints <= 0 {
-		return
-	}
-
-	// Применяем улучшение
-	switch stat {
-	case "damage":
-		player.Stats["damage"] += 20
-		log.Printf("Урон увеличен до: %.1f", player.Stats["damage"])
-	case "health":
-		player.Stats["health"] += 15
-		player.Stats["max_health"] += 15
-		log.Printf("Здоровье увеличено до: %.1f", player.Stats["health"])
-	case "speed":
-		if player.Stats["speed"] < 15 {
-			player.Stats["speed"] += 0.5
-			log.Printf("Скорость увеличена до: %.1f", player.Stats["speed"])
+		if err != nil {
+			log.Printf("Ошибка отправки уровня игроку %d: %v", g.ID, err)
 		}
-	case "reload":
-		newValue := player.Stats["fire_rate"] + 1.0
-		player.Stats["fire_rate"] = newValue
-		log.Printf("Скорострельность улучшена до: %.0f выстр/сек", player.Stats["fire_rate"])
-	default:
-		log.Printf("Неизвестный стат для улучшения: %s", stat)
-		return
 	}
 
-	player.SkillPoints--
-
-	// Асинхронная отправка обновлений
-	go g.sendPlayerUpdate(playerID)
-
-	log.Printf("Игрок %d улучшил %s. Осталось очков: %d",
-		playerID, stat, player.SkillPoints)
-}
-
-func (g *Game) sendPlayerUpdate(playerID uint) {
-	g.Mutex.RLock()
-	defer g.Mutex.RUnlock()
-
-	player, exists := g.Players[playerID]
-	if !exists || player.Conn == nil {
-		return
-	}
-
-	err := player.Conn.WriteJSON(map[string]interface{}{
-		"type":         "upgrade",
-		"skill_points": player.SkillPoints,
-		"stats":        player.Stats,
-	})
-
-	if err != nil {
-		log.Printf("Ошибка отправки обновления игроку %d: %v", playerID, err)
-	}
+	log.Printf("Кап до нового уровня: %d", (g.NewLvlExp))
 }
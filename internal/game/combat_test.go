@@ -0,0 +1,67 @@
+package game
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSegmentCircleHit(t *testing.T) {
+	tests := []struct {
+		name           string
+		x0, y0, x1, y1 float64
+		hit            bool
+		t              float64
+	}{
+		{"проходит сквозь центр", -20, 0, 20, 0, true, 0.25},
+		{"касается края", -20, 10, 20, 10, true, 0.5},
+		{"проходит мимо", -20, 11, 20, 11, false, 0},
+		{"начинается внутри", 0, 5, 20, 5, true, 0},
+		{"останавливается до окружности", -20, 0, -11, 0, false, 0},
+		{"начинается за окружностью", 11, 0, 20, 0, false, 0},
+		{"нулевой отрезок снаружи", 15, 0, 15, 0, false, 0},
+		{"перескакивает окружность за шаг", -1000, 0, 1000, 0, true, 0.495},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := segmentCircleHit(tt.x0, tt.y0, tt.x1, tt.y1, 0, 0, 10)
+			if ok != tt.hit {
+				t.Fatalf("попадание %v, ожидалось %v", ok, tt.hit)
+			}
+			if ok && math.Abs(got-tt.t) > 1e-9 {
+				t.Fatalf("t = %v, ожидалось %v", got, tt.t)
+			}
+		})
+	}
+}
+
+// Пуля, пролетающая за шаг больше диаметра игрока, не проскакивает его
+func TestFastBulletDoesNotTunnel(t *testing.T) {
+	g := NewGame(WithSeed(1))
+	g.CleanupObjects()
+	for _, id := range []uint{1, 2} {
+		if err := g.AddPlayer(id, nil); err != nil {
+			t.Fatal(err)
+		}
+		g.Players[id].protected = false
+	}
+	shooter, target := g.Players[1], g.Players[2]
+	shooter.X, shooter.Y = 1000, 1000
+	target.X, target.Y = 1250, 1000
+	shooter.Stats["bullet_speed"] = 30000
+	if step := 30000 * g.TickRate.Seconds(); step <= 4*PlayerRadius {
+		t.Fatalf("пуля проходит за шаг %.0f пикселей, тест не проверяет туннелирование", step)
+	}
+
+	health := target.Stats["health"]
+	shooter.input = PlayerInputData{Shoot: true}
+	g.step()
+	shooter.input = PlayerInputData{}
+	for i := 0; i < 5; i++ {
+		g.step()
+	}
+
+	if target.Stats["health"] >= health {
+		t.Fatal("быстрая пуля проскочила игрока")
+	}
+}
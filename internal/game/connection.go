@@ -0,0 +1,30 @@
+package game
+
+import (
+	"time"
+
+	"gameCore/internal/protocol"
+)
+
+// Connection - исходящий канал к клиенту. Send не должен блокировать игровой
+// цикл: реализация ставит сообщение в очередь и отправляет его в своей горутине
+type Connection interface {
+	// Send упаковывает нагрузку в конверт типа msgType и ставит в очередь.
+	// Ненадёжные типы (снимки состояния) могут быть отброшены при переполнении
+	Send(msgType protocol.MessageType, payload interface{}) error
+	// Close отправляет оставшиеся сообщения и закрывает соединение
+	Close()
+}
+
+// rttReporter реализуют соединения, которые измеряют задержку до клиента
+type rttReporter interface {
+	RTT() time.Duration
+}
+
+// connRTT возвращает задержку соединения в миллисекундах, 0 - неизвестна
+func connRTT(conn Connection) int {
+	if r, ok := conn.(rttReporter); ok {
+		return int(r.RTT().Milliseconds())
+	}
+	return 0
+}
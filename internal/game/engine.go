@@ -1,70 +1,123 @@
 package game
 
 import (
+	"context"
 	"errors"
 	"log"
 	"math"
 	"math/rand"
+	"sort"
 	"sync"
 	"time"
 
-	"github.com/gorilla/websocket"
+	"gameCore/internal/config"
+	"gameCore/internal/protocol"
 )
 
 // TODO:
-// 3. Добавить механизм для обработки столкновений (чуть позже) t2
-// 6. Добавить механизм для обработки улучшений t1
-// 7. Добавить механизм для обработки специлизаций t2
-// 8. Добавить объекты на карте для фарма опыта t3
 // 9. Сделать более плавное передвижение t4
 // 10. Сделать фиксированное положение камеры t4
 // 11. Сделать более плавную анимацию t4
 // 12. Сделать более плавную анимацию выстрелов t4
 
 const (
-	GameTick          = 16 * time.Millisecond // ~60 FPS
-	MaxInputQueue     = 1000                  // Буфер канала ввода
-	BasePlayerSpeed   = 10.0
+	GameTick          = 16 * time.Millisecond // ~60 FPS, шаг симуляции по умолчанию
+	MaxInputQueue     = 1000                  // Предел очереди команд комнаты
+	MaxStepsPerFrame  = 5                     // Предел шагов за один тик таймера, чтобы не уйти в "спираль смерти"
+	BasePlayerSpeed   = 240.0                 // Пикселей в секунду
+	BaseBulletSpeed   = 600.0                 // Пикселей в секунду
 	BasePlayerHealth  = 100.0
+	BaseBodyDamage    = 0.0 // Таранный урон в секунду контакта: без улучшения тарана нет
 	CollisionDistance = 10.0
 	PlayerRadius      = 10.0 // Радиус игрока
 	BulletRadius      = 3.0  // Радиус пули
 	ObjectRadius      = 15.0
+	CollisionCellSize = 64.0 // Размер ячейки сетки коллизий
 	RespawnTime       = 5 * time.Second
+	RespawnMinDelay   = 2 * time.Second // Раньше этого после смерти возродиться по просьбе нельзя
+	SpawnProtection   = 3 * time.Second // Неуязвимость после появления
 	MinX              = 0
 	MaxX              = 1880
 	MinY              = 0
 	MaxY              = 1040
-	// BulletLifetime    = 5 * time.Second
+	BulletLifetime    = 3 * time.Second
 )
 
 type Game struct {
-	Players      map[uint]*Player
-	Objects      []*Object
-	Mutex        sync.RWMutex
-	Inputs       chan PlayerInput
-	Bullets      []*Bullet
-	RespawnDelay time.Duration
-	MaxObjects   int
-	Running      bool          // Флаг работы игрового цикла
-	Done         chan struct{} // Канал для остановки игры
+	ID               string // Идентификатор комнаты
+	SessionID        uint   // ID записи models.GameSession
+	MaxPlayers       int    // Максимум игроков в комнате (0 - без ограничений)
+	Players          map[uint]*Player
+	Spectators       map[uint]*Spectator
+	Objects          []*Object
+	Mutex            sync.RWMutex
+	Bullets          []*Bullet
+	TickRate         time.Duration // Фиксированный шаг симуляции
+	MaxRewind        time.Duration // Предел отката целей при компенсации лага
+	ViewRadius       float64       // Радиус зоны интереса клиента
+	InterestCellSize float64       // Размер ячейки сетки интереса
+	Tick             uint64        // Номер текущего шага симуляции
+	Seed             int64         // Зерно генератора случайных чисел симуляции
+	RespawnDelay     time.Duration
+	MatchTime        time.Duration // Длительность матча, 0 - без ограничения
+	ReconnectGrace   time.Duration // Сколько отключившийся игрок ждёт переподключения
+	MaxObjects       int
+	Running          bool          // Флаг работы игрового цикла
+	Done             chan struct{} // Канал для остановки игры
+	nextBulletID     uint
+	nextObjectID     uint
+	rng              *rand.Rand   // Единственный источник случайности симуляции
+	playerOrder      []*Player    // Игроки по возрастанию ID для детерминированного обхода
+	classes          *classTree   // Дерево специализаций комнаты
+	upgrades         *upgradeSet  // Каталог улучшений комнаты
+	objectKinds      *objectKinds // Виды объектов для фарма
+	population       *population  // Зоны и правила появления объектов
+	scheduler        *scheduler   // Отложенные действия по тикам
+	onMatchEnd       func(*Game)
+	onPlayerExpired  func(*Game)
+	inbox            *inbox     // Ввод игроков до следующего тика
+	anticheat        *antiCheat // Проверка ввода и очки подозрения
+	onCheat          func(*Game, CheatReport)
+	eventHandlers    []EventHandler // Подписчики событий комнаты
+	events           []Event        // События с прошлой рассылки, под Mutex
+	replayDir        string
+	replayConfig     config.GameConfig
+	recorder         *replayRecorder // Запись матча, nil - не ведётся
+
+	// Сетки коллизий перестраиваются каждый шаг после движения игроков
+	playerGrid *spatialGrid[*Player]
+	objectGrid *spatialGrid[*Object]
+	// Пули этого шага ждут проверки с откатом до перестройки сеток
+	newBullets []*Bullet
 }
 
 type Player struct {
 	ID    uint
 	X, Y  float64
-	Angle float64         `json:"angle"`
-	Conn  *websocket.Conn `json:"-"`
-
-	Level            int
-	XP               int
-	NewLvlExp        int
-	SkillPoints      int `json:"skill_points"`
-	Stats            map[string]float64
-	Alive            bool
-	FailedBroadcasts int
-	RespawnTimer     *time.Timer `json:"-"`
-	lastShot         time.Time   // Время последнего выстрела для контроля скорострельности
+	Angle float64    `json:"angle"`
+	Conn  Connection `json:"-"`
+
+	Level          int
+	XP             int
+	NewLvlExp      int
+	SkillPoints    int `json:"skill_points"`
+	Stats          map[string]float64
+	Upgrades       map[string]int // Уровень каждого купленного улучшения
+	Alive          bool
+	lastShotTick   uint64 // Тик последнего выстрела для контроля скорострельности
+	hasShot        bool   // Игрок уже стрелял хотя бы раз
+	protected      bool   // Неуязвимость после появления
+	diedTick       uint64 // Тик последней смерти
+	input          PlayerInputData
+	shootQueued    bool             // Выстрел был запрошен с прошлого тика, даже если кнопку уже отпустили
+	view           *clientView      // Подтверждённые клиентом снимки для дельта-сжатия
+	history        *positionHistory // Положения за последние тики для компенсации лага
+	lastSeq        uint32           // Номер последнего принятого ввода
+	lastCommandSeq uint32           // Номер последней выполненной команды
+	processedSeq   uint32           // Номер последнего применённого в симуляции ввода
+	class          *tankClass       // Текущая специализация
+	disconnected   bool             // Соединение разорвано, игрок ждёт переподключения
+	resumeToken    string           // Токен перехвата сессии с нового соединения
 }
 
 type Bullet struct {
@@ -74,34 +127,42 @@ type Bullet struct {
 	Angle     float64
 	Speed     float64
 	Damage    float64
+	Radius    float64
 	Active    bool
-	CreatedAt time.Time // Добавляем время создания для возможного времени жизни пули
+	SpawnTick uint64 // Тик создания для контроля времени жизни пули
+	viewTick  uint64 // Тик, который видел стрелок, для проверки с откатом
 }
 
 type bulletState struct {
-	X     float64 `json:"x"`
-	Y     float64 `json:"y"`
-	Angle float64 `json:"angle"`
+	ID     uint    `json:"id"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Angle  float64 `json:"angle"`
+	Radius float64 `json:"radius"`
 }
 
 type playerState struct {
-	ID               uint               `json:"id"`
-	X                float64            `json:"x"`
-	Y                float64            `json:"y"`
-	Angle            float64            `json:"angle"`
-	Level            int                `json:"level"`
-	NewLvlExp        int                `json:"new_lvl_epx"`
-	SkillPoints      int                `json:"skill_points"`
-	FailedBroadcasts int                `json:"failed_broadcasts"`
-	Stats            map[string]float64 `json:"stats"`
+	ID          uint               `json:"id"`
+	X           float64            `json:"x"`
+	Y           float64            `json:"y"`
+	Angle       float64            `json:"angle"`
+	Level       int                `json:"level"`
+	NewLvlExp   int                `json:"new_lvl_epx"`
+	SkillPoints int                `json:"skill_points"`
+	Stats       map[string]float64 `json:"stats"`
+	LastSeq     uint32             `json:"last_seq"` // Последний применённый ввод игрока
+	Class       string             `json:"class"`
+	Protected   bool               `json:"protected"`
+	RTT         int                `json:"rtt"` // Задержка до клиента в миллисекундах
 }
 
 type objectState struct {
-	ID uint `json:"id"`
-	// Type   string  `json:"type"`
+	ID     uint    `json:"id"`
+	Type   string  `json:"type"`
 	X      float64 `json:"x"`
 	Y      float64 `json:"y"`
 	Health int     `json:"health"`
+	Radius float64 `json:"radius"`
 }
 
 type PlayerInputData struct {
@@ -109,199 +170,412 @@ type PlayerInputData struct {
 	Angle                 float64 `json:"angle"`
 	Shoot                 bool
 	UpgradeStat           string `json:"stat"`
+	Ack                   uint32 `json:"ack"`       // ID последнего полученного клиентом снимка
+	ViewTick              uint64 `json:"view_tick"` // Тик снимка, который клиент видел при выстреле
+	Seq                   uint32 `json:"seq"`       // Монотонный номер ввода для согласования на клиенте
+	Class                 string `json:"class"`     // Выбор специализации
+	Respawn               bool   `json:"respawn"`   // Просьба возродиться, не дожидаясь RespawnTime
 }
 
 type PlayerInput struct {
 	ID    uint
 	Input PlayerInputData
+	Shot  bool // Выстрел из вытесненного более новым сообщения
+}
+
+// Option настраивает экземпляр игры при создании
+type Option func(*Game)
+
+// WithID задаёт идентификатор комнаты
+func WithID(id string) Option {
+	return func(g *Game) {
+		g.ID = id
+	}
 }
 
-func NewGame() *Game {
+// WithMaxPlayers ограничивает количество игроков в комнате
+func WithMaxPlayers(maxPlayers int) Option {
+	return func(g *Game) {
+		g.MaxPlayers = maxPlayers
+	}
+}
+
+// WithTickRate задаёт фиксированный шаг симуляции
+func WithTickRate(tickRate time.Duration) Option {
+	return func(g *Game) {
+		if tickRate > 0 {
+			g.TickRate = tickRate
+		}
+	}
+}
+
+// WithSeed фиксирует зерно симуляции: одинаковое зерно и одинаковый поток
+// ввода дают одинаковое состояние мира
+func WithSeed(seed int64) Option {
+	return func(g *Game) {
+		g.Seed = seed
+	}
+}
+
+func NewGame(opts ...Option) *Game {
 	game := &Game{
-		Players:      make(map[uint]*Player),
-		Inputs:       make(chan PlayerInput, MaxInputQueue),
-		Done:         make(chan struct{}),
-		Running:      false,
-		Objects:      make([]*Object, 0),
-		MaxObjects:   30,              // default object count
-		RespawnDelay: 1 * time.Minute, // default respawn time
+		TickRate:         GameTick,
+		Seed:             time.Now().UnixNano(),
+		MaxRewind:        DefaultMaxRewind,
+		ReconnectGrace:   DefaultReconnectGrace,
+		ViewRadius:       DefaultViewRadius,
+		InterestCellSize: DefaultInterestCellSize,
+		Players:          make(map[uint]*Player),
+		Spectators:       make(map[uint]*Spectator),
+		Done:             make(chan struct{}),
+		Running:          false,
+		Objects:          make([]*Object, 0),
+		playerGrid:       newSpatialGrid[*Player](CollisionCellSize),
+		objectGrid:       newSpatialGrid[*Object](CollisionCellSize),
+		classes:          defaultClassTree,
+		upgrades:         defaultUpgradeSet,
+		objectKinds:      defaultObjectKinds,
+		population:       newPopulation(nil, 0, 0),
+		scheduler:        newScheduler(),
+		inbox:            newInbox(),
+		anticheat:        newAntiCheat(),
+		MaxObjects:       30,              // default object count
+		RespawnDelay:     1 * time.Minute, // default respawn time
 	}
+	for _, opt := range opts {
+		opt(game)
+	}
+	game.rng = rand.New(rand.NewSource(game.Seed))
 	game.InitObjectSystem(game.MaxObjects, game.RespawnDelay)
 	return game
 }
 
 // AddPlayer добавляет нового игрока с базовыми характеристиками
-func (g *Game) AddPlayer(id uint, conn *websocket.Conn) error {
+func (g *Game) AddPlayer(id uint, conn Connection) error {
 	g.Mutex.Lock()
 	defer g.Mutex.Unlock()
 
 	// Проверяем, не существует ли уже игрок с таким ID
 	if _, exists := g.Players[id]; exists {
-		return errors.New("игрок с таким ID уже существует")
+		return ErrPlayerExists
+	}
+	if g.MaxPlayers > 0 && len(g.Players) >= g.MaxPlayers {
+		return ErrRoomFull
 	}
 
 	g.Players[id] = &Player{
-		ID:               id,
-		X:                float64(rand.Intn(MaxX) + 60),
-		Y:                float64(rand.Intn(MaxY) + 40),
-		Conn:             conn,
-		Level:            1,
-		XP:               0,
-		NewLvlExp:        100,
-		SkillPoints:      0,
-		FailedBroadcasts: 0,
-		Alive:            true,
+		ID:          id,
+		X:           float64(g.rng.Intn(MaxX) + 60),
+		Y:           float64(g.rng.Intn(MaxY) + 40),
+		Conn:        conn,
+		Level:       1,
+		XP:          0,
+		NewLvlExp:   100,
+		SkillPoints: 0,
+		Alive:       true,
+		view:        newClientView(),
+		history:     newPositionHistory(g.historySize()),
+		class:       g.classes.root,
+		Upgrades:    make(map[string]int),
 		Stats: map[string]float64{
-			"health":     BasePlayerHealth,
-			"max_health": BasePlayerHealth,
-			"damage":     10,
-			"speed":      40,
-			"fire_rate":  1,
-			// "body_damage":  10,
-			"bullet_speed": 10,
-			"reload_speed": 3,
+			"health":       BasePlayerHealth,
+			"max_health":   BasePlayerHealth,
+			"damage":       10,
+			"speed":        BasePlayerSpeed,
+			"fire_rate":    1,
+			"body_damage":  BaseBodyDamage,
+			"bullet_speed": BaseBulletSpeed,
 		},
-		lastShot: time.Now(),
 	}
+	g.addToOrder(g.Players[id])
+	g.issueResumeToken(g.Players[id])
+	g.record(replayEvent{Kind: replayJoin, PlayerID: id})
+	g.protectPlayer(g.Players[id])
 	log.Printf("Добавлен игрок %d", id)
 	return nil
 }
 
 func (g *Game) InitObjectSystem(maxObjects int, respawnDelay time.Duration) {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
 	g.MaxObjects = maxObjects
 	g.RespawnDelay = respawnDelay
-	g.CheckObjects()
+	// Карта сразу заполняется полностью, дальше популяцию держит шаг симуляции
+	g.maintainPopulation(true)
 }
 
 func (g *Game) Start() {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
 	if g.Running {
 		return
 	}
 	g.Running = true
+	g.scheduleMatchEnd()
+	g.startRecording()
+
+	go g.loop()
+}
+
+func (g *Game) scheduleMatchEnd() {
+	if g.MatchTime > 0 {
+		g.after(g.MatchTime, roomOwner, g.endMatch)
+	}
+}
+
+// loop разделяет сбор ввода и симуляцию: ввод только запоминается,
+// а мир продвигается фиксированными шагами TickRate независимо от
+// частоты сообщений клиентов и дрожания таймера
+func (g *Game) loop() {
+	ticker := time.NewTicker(g.TickRate)
+	defer ticker.Stop()
+
+	last := time.Now()
+	var accumulator time.Duration
+
+	for {
+		select {
+		case <-g.Done:
+			return
+		case now := <-ticker.C:
+			accumulator += now.Sub(last)
+			last = now
+
+			// Ввод, накопленный с прошлого тика, попадает в ближайший шаг
+			g.drainInbox()
+
+			steps := 0
+			for accumulator >= g.TickRate && steps < MaxStepsPerFrame {
+				g.step()
+				accumulator -= g.TickRate
+				steps++
+			}
+			if steps == MaxStepsPerFrame {
+				// Сервер не успевает - отбрасываем накопленное отставание
+				accumulator = 0
+			}
 
-	go func() {
-		ticker := time.NewTicker(GameTick)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-g.Done:
-				return
-			case input := <-g.Inputs:
-				g.handleInput(input)
-			case <-ticker.C:
-				g.update()
+			if steps > 0 {
 				g.broadcastState()
-				g.updateBullets()
 			}
+			g.publishEvents()
 		}
-	}()
+	}
 }
 
-// Stop останавливает игровой цикл
+// Stop останавливает игровой цикл и ждёт закрытия записи матча
 func (g *Game) Stop() {
+	g.StopContext(context.Background())
+}
+
+// StopContext останавливает комнату и ждёт закрытия записи матча не
+// дольше ctx. Диск ждём без g.Mutex, чтобы не держать комнату
+func (g *Game) StopContext(ctx context.Context) error {
+	g.Mutex.Lock()
+	var recorder *replayRecorder
 	if g.Running {
+		g.Running = false
+		g.scheduler.clear()
 		g.CleanupObjects()
+		recorder = g.stopRecording()
 		close(g.Done)
 	}
+	g.Mutex.Unlock()
+
+	if recorder == nil {
+		return nil
+	}
+	return recorder.wait(ctx)
 }
 
-func (g *Game) handleInput(input PlayerInput) {
+// collectInput запоминает последний ввод игрока до следующего шага симуляции.
+// Разовые команды выполняет collectCommand
+func (g *Game) collectInput(input PlayerInput) {
 	g.Mutex.Lock()
 	defer g.Mutex.Unlock()
 
 	player, ok := g.Players[input.ID]
-	if !ok {
+	// Ввод, оставшийся в очереди от разорванного соединения, не применяем
+	if !ok || player.disconnected {
 		return
 	}
 
-	if input.Input.UpgradeStat != "" {
-		log.Printf("Стат для апдейта %s", input.Input.UpgradeStat)
-		g.handleUpgrade(input.ID, input.Input.UpgradeStat)
+	player.view.ack(input.Input.Ack)
+
+	// Дубликаты и ввод, пришедший не по порядку, отбрасываем.
+	// Нулевой номер - клиент без нумерации, его ввод принимаем как есть
+	if input.Input.Seq != 0 {
+		if input.Input.Seq <= player.lastSeq {
+			return
+		}
+		player.lastSeq = input.Input.Seq
 	}
+	g.record(replayEvent{Kind: replayInput, PlayerID: input.ID, Input: input.Input, Shot: input.Shot})
 
-	speed := player.Stats["speed"]
+	player.input = input.Input
+	if input.Input.Shoot || input.Shot {
+		player.shootQueued = true
+	}
+}
 
-	// Предварительные координаты
-	newX, newY := player.X, player.Y
+// AckSnapshot принимает подтверждение снимка отдельно от ввода: клиент без
+// движения тоже должен сдвигать базу дельта-сжатия
+func (g *Game) AckSnapshot(id uint, ack uint32) {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
 
-	if input.Input.Up {
-		newY -= speed
+	if player, ok := g.Players[id]; ok && !player.disconnected {
+		player.view.ack(ack)
 	}
-	if input.Input.Down {
-		newY += speed
+}
+
+// step продвигает симуляцию на один фиксированный шаг TickRate
+func (g *Game) step() {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	dt := g.TickRate.Seconds()
+
+	for _, player := range g.playerOrder {
+		g.applyInput(player, dt)
 	}
-	if input.Input.Left {
-		newX -= speed
+	g.updateObjects(dt)
+	g.rebuildCollisionGrids()
+	g.resolveBodyCollisions(dt)
+	g.rewindNewBullets(dt)
+	g.updateBullets(dt)
+
+	g.Tick++
+	g.scheduler.runDue(g.Tick)
+	g.recordPositions()
+	if g.Tick%PopulationInterval == 0 {
+		g.maintainPopulation(false)
 	}
-	if input.Input.Right {
-		newX += speed
+	g.recordChecksum()
+}
+
+// applyInput применяет последний ввод игрока один раз за шаг: скорость * dt
+func (g *Game) applyInput(player *Player, dt float64) {
+	if !player.Alive {
+		player.shootQueued = false
+		return
 	}
 
-	// Применяем ограничения
-	if newX < MinX {
-		newX = MinX
+	input := player.input
+	player.processedSeq = input.Seq
+
+	var dirX, dirY float64
+	if input.Up {
+		dirY -= 1
+	}
+	if input.Down {
+		dirY += 1
 	}
-	if newX > MaxX {
-		newX = MaxX
+	if input.Left {
+		dirX -= 1
 	}
-	if newY < MinY {
-		newY = MinY
+	if input.Right {
+		dirX += 1
 	}
-	if newY > MaxY {
-		newY = MaxY
+
+	// Нормализуем направление, чтобы по диагонали скорость не росла
+	if length := math.Hypot(dirX, dirY); length > 0 {
+		speed := player.stat("speed")
+		player.X += dirX / length * speed * dt
+		player.Y += dirY / length * speed * dt
 	}
 
-	// Обновляем координаты
-	player.X = newX
-	player.Y = newY
-	player.Angle = input.Input.Angle
+	// Применяем ограничения
+	clampToWorld(player)
+	player.Angle = input.Angle
 
-	if input.Input.Shoot {
-		g.shootBullet(player)
+	if input.Shoot || player.shootQueued {
+		g.shootBullet(player, input.ViewTick)
 	}
+	player.shootQueued = false
 }
 
-func (g *Game) shootBullet(player *Player) {
+// shootBullet создаёт пулю. Первая проверка попадания идёт по целям,
+// откатанным к тику viewTick, который видел стрелок, в rewindNewBullets
+func (g *Game) shootBullet(player *Player, viewTick uint64) {
 	if !player.Alive {
 		return
 	}
 
-	now := time.Now()
-	fireRate := player.Stats["fire_rate"]
+	fireRate := player.stat("fire_rate")
 
 	// Безопасный расчет интервала
 	if fireRate > 0 {
 		minInterval := time.Duration(float64(time.Second) / fireRate)
-		if now.Sub(player.lastShot) < minInterval {
+		if player.hasShot && g.ticksToDuration(g.Tick-player.lastShotTick) < minInterval {
 			return
 		}
 	} else {
 		return // Если скорострельность нулевая - не стреляем
 	}
 
-	bullet := &Bullet{
-		ID:        uint(len(g.Bullets) + 1),
-		OwnerID:   player.ID,
-		X:         player.X,
-		Y:         player.Y,
-		Angle:     player.Angle,
-		Speed:     player.Stats["bullet_speed"],
-		Damage:    player.Stats["damage"],
-		Active:    true,
-		CreatedAt: now,
+	player.lastShotTick = g.Tick // Обновляем время последнего выстрела
+	player.hasShot = true
+
+	speed := player.stat("bullet_speed")
+	damage := player.stat("damage")
+	log.Printf("Игрок %d (%s) выстрелил. Урон: %.1f, Скорость: %.1f",
+		player.ID, player.class.Name, damage, speed)
+
+	// Каждый ствол класса выпускает свою пулю
+	for _, angle := range player.class.barrelAngles(player.Angle) {
+		g.nextBulletID++
+		bullet := &Bullet{
+			ID:        g.nextBulletID,
+			OwnerID:   player.ID,
+			X:         player.X,
+			Y:         player.Y,
+			Angle:     angle,
+			Speed:     speed,
+			Damage:    damage,
+			Radius:    player.class.BulletRadius,
+			Active:    true,
+			SpawnTick: g.Tick,
+			viewTick:  viewTick,
+		}
+
+		g.emit(Event{Kind: EventBulletFired, SourceID: player.ID, BulletID: bullet.ID, X: bullet.X, Y: bullet.Y})
+		g.newBullets = append(g.newBullets, bullet)
 	}
+}
 
-	player.lastShot = now // Обновляем время последнего выстрела
-	g.Bullets = append(g.Bullets, bullet)
+// rewindNewBullets проверяет пули, выпущенные на этом шаге, с откатом
+// целей. Вызывается после перестройки сеток коллизий, чтобы путь пули
+// сверялся с нынешними положениями игроков, а не с прошлым шагом
+func (g *Game) rewindNewBullets(dt float64) {
+	for _, bullet := range g.newBullets {
+		if g.rewindHitTest(bullet, bullet.viewTick, dt) {
+			g.Bullets = append(g.Bullets, bullet)
+		}
+	}
+	g.newBullets = g.newBullets[:0]
+}
 
-	log.Printf("Игрок %d выстрелил. Урон: %.1f, Скорость: %.1f",
-		player.ID, bullet.Damage, bullet.Speed)
+// ticksToDuration переводит количество шагов симуляции во время
+func (g *Game) ticksToDuration(ticks uint64) time.Duration {
+	return time.Duration(ticks) * g.TickRate
 }
 
-func (g *Game) updateBullets() {
+// Clock возвращает симулированное время матча: сколько шагов прошло, а не
+// сколько прошло по часам сервера
+func (g *Game) Clock() time.Duration {
+	return g.ticksToDuration(g.Tick)
+}
+
+// durationToTicks переводит время в число шагов симуляции с округлением вверх
+func (g *Game) durationToTicks(d time.Duration) uint64 {
+	return uint64((d + g.TickRate - 1) / g.TickRate)
+}
+
+func (g *Game) updateBullets(dt float64) {
 	var activeBullets []*Bullet
-	now := time.Now()
 
 	for _, bullet := range g.Bullets {
 		if !bullet.Active {
@@ -309,11 +583,12 @@ func (g *Game) updateBullets() {
 		}
 
 		// Обновляем позицию пули
-		bullet.X += bullet.Speed * math.Cos(bullet.Angle)
-		bullet.Y += bullet.Speed * math.Sin(bullet.Angle)
+		fromX, fromY := bullet.X, bullet.Y
+		bullet.X += bullet.Speed * math.Cos(bullet.Angle) * dt
+		bullet.Y += bullet.Speed * math.Sin(bullet.Angle) * dt
 
-		// Проверяем коллизии
-		if !g.checkBulletCollisions(bullet) || !g.checkBulletObjectCollisions(bullet) {
+		// Проверяем коллизии на всём пройденном за шаг отрезке
+		if !g.checkBulletCollisions(bullet, fromX, fromY) {
 			continue
 		}
 
@@ -323,8 +598,8 @@ func (g *Game) updateBullets() {
 			continue
 		}
 
-		// Проверяем время жизни пули (если нужно)
-		if now.Sub(bullet.CreatedAt) > 3*time.Second {
+		// Проверяем время жизни пули
+		if g.ticksToDuration(g.Tick-bullet.SpawnTick) > BulletLifetime {
 			bullet.Active = false
 			continue
 		}
@@ -334,36 +609,19 @@ func (g *Game) updateBullets() {
 	g.Bullets = activeBullets
 }
 
-func (g *Game) update() {
-	g.Mutex.RLock()
-	defer g.Mutex.RUnlock()
-
-	for _, player := range g.Players {
-		err := player.Conn.WriteJSON(g.serializeState())
-		if err != nil {
-			log.Printf("ошибка отправки данных игроку %d: %v", player.ID, err)
-		}
-	}
-}
-
-func (g *Game) serializeState() interface{} {
-	g.Mutex.RLock()
-	defer g.Mutex.RUnlock()
-
+// serializeState собирает полное состояние мира. Вызывается под g.Mutex;
+// результат не разделяет изменяемых данных с игрой и хранится в истории клиентов
+func (g *Game) serializeState() worldState {
 	// Объединяем все игровые сущности в единый ответ
-	return map[string]interface{}{
-		"players": g.serializePlayers(),
-		"bullets": g.serializeBullets(),
-		"objects": g.serializeObjects(), // Добавляем игровые объекты
-		// "meta": map[string]interface{}{
-		// 	"server_time": time.Now().UnixMilli(),
-		// 	// "version":     g.Config.Version,
-		// },
+	return worldState{
+		Players: g.serializePlayers(),
+		Bullets: g.serializeBullets(),
+		Objects: g.serializeObjects(), // Добавляем игровые объекты
 	}
 }
 
 func (g *Game) serializePlayers() map[uint]playerState {
-	players := make(map[uint]playerState)
+	players := make(map[uint]playerState, len(g.Players))
 	for id, p := range g.Players {
 		players[id] = playerState{
 			ID:          p.ID,
@@ -371,40 +629,59 @@ func (g *Game) serializePlayers() map[uint]playerState {
 			Y:           p.Y,
 			Angle:       p.Angle,
 			Level:       p.Level,
-			Stats:       p.Stats,
+			NewLvlExp:   p.NewLvlExp,
+			Stats:       copyStats(p.Stats),
 			SkillPoints: p.SkillPoints,
+			LastSeq:     p.processedSeq,
+			Class:       p.class.Name,
+			Protected:   p.protected,
+			RTT:         connRTT(p.Conn),
 		}
 	}
 	return players
 }
 
-func (g *Game) serializeBullets() []bulletState {
-	bullets := make([]bulletState, 0, len(g.Bullets))
+func (g *Game) serializeBullets() map[uint]bulletState {
+	bullets := make(map[uint]bulletState, len(g.Bullets))
 	for _, b := range g.Bullets {
-		bullets = append(bullets, bulletState{
-			X:     b.X,
-			Y:     b.Y,
-			Angle: b.Angle,
-		})
+		bullets[b.ID] = bulletState{
+			ID:     b.ID,
+			X:      b.X,
+			Y:      b.Y,
+			Angle:  b.Angle,
+			Radius: b.Radius,
+		}
 	}
 	return bullets
 }
 
-func (g *Game) serializeObjects() []objectState {
-	objects := make([]objectState, 0, len(g.Objects))
+func (g *Game) serializeObjects() map[uint]objectState {
+	objects := make(map[uint]objectState, len(g.Objects))
 	for _, obj := range g.Objects {
 		if obj.Active {
-			objects = append(objects, objectState{
+			// Здоровье уходит клиенту целыми единицами с округлением вверх:
+			// почти разрушенный объект не должен выглядеть уничтоженным
+			objects[obj.ID] = objectState{
 				ID:     obj.ID,
+				Type:   obj.Type,
 				X:      obj.X,
 				Y:      obj.Y,
-				Health: obj.Health,
-			})
+				Health: int(math.Ceil(obj.Health)),
+				Radius: obj.Radius,
+			}
 		}
 	}
 	return objects
 }
 
+func copyStats(stats map[string]float64) map[string]float64 {
+	copied := make(map[string]float64, len(stats))
+	for key, value := range stats {
+		copied[key] = value
+	}
+	return copied
+}
+
 // func (g *Game) serializeState() interface{} {
 
 // 	players := make(map[uint]playerState)
@@ -435,77 +712,88 @@ func (g *Game) serializeObjects() []objectState {
 // 	}
 // }
 
+// broadcastState раздаёт каждому игроку дельту относительно подтверждённого
+// им снимка, ограниченную его зоной интереса. Send только ставит сообщение
+// в очередь соединения, поэтому медленный клиент не тормозит тик
 func (g *Game) broadcastState() {
-	g.Mutex.RLock()
-	state := struct {
-		Players map[uint]*Player `json:"players"`
-		Bullets []*Bullet        `json:"bullets"`
-	}{
-		Players: g.Players,
-		Bullets: g.Bullets,
-	}
-
-	var playersToRemove []uint
-	players := make([]*Player, 0, len(g.Players))
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
 
-	// Создаем копию игроков для безопасной итерации
+	world := g.serializeState()
+	grid := g.interestGrid(world)
 	for _, p := range g.Players {
-		players = append(players, p)
-	}
-	g.Mutex.RUnlock()
-
-	// Проверяем соединения и считаем ошибки
-	for _, p := range players {
 		if p.Conn == nil {
-			playersToRemove = append(playersToRemove, p.ID)
 			continue
 		}
 
-		// Проверяем состояние соединения перед отправкой
-		if err := p.Conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
-			p.FailedBroadcasts++
-		} else {
-			if err := p.Conn.WriteJSON(state); err != nil {
-				log.Printf("❌ Ошибка отправки состояния игроку %d: %v", p.ID, err)
-				p.FailedBroadcasts++
-			} else {
-				p.FailedBroadcasts = 0 // Сброс при успешной отправке
-			}
+		visible := world.visibleFrom(grid, p.X, p.Y, g.ViewRadius)
+		if err := g.sendWorld(p.Conn, p.view, visible); err != nil {
+			log.Printf("❌ Ошибка отправки состояния игроку %d: %v", p.ID, err)
 		}
+	}
+	g.broadcastSpectators(world, grid)
+}
 
-		if p.FailedBroadcasts >= 3 {
-			playersToRemove = append(playersToRemove, p.ID)
+// sendWorld отправляет события зоны интереса и снимок видимой части мира.
+// Закрытое соединение ошибкой не считается: его уберёт читатель
+func (g *Game) sendWorld(conn Connection, view *clientView, visible worldState) error {
+	if interest := view.updateInterest(visible); !interest.empty() {
+		if err := conn.Send(protocol.TypeInterest, interest); err != nil && !errors.Is(err, ErrConnectionClosed) {
+			return err
 		}
 	}
 
-	// Безопасное удаление игроков с полной блокировкой
-	if len(playersToRemove) > 0 {
-		g.Mutex.Lock()
-		defer g.Mutex.Unlock()
-
-		for _, id := range playersToRemove {
-			if player, exists := g.Players[id]; exists {
-				// Дополнительная проверка состояния соединения
-				if player.Conn != nil {
-					player.Conn.Close()
-				}
-				delete(g.Players, id)
-				log.Printf("⚠️ Игрок %d удален после %d неудачных попыток отправки",
-					id, player.FailedBroadcasts)
-			}
-		}
+	snapshot := view.build(visible, g.Tick)
+	if err := conn.Send(protocol.TypeState, snapshot); err != nil && !errors.Is(err, ErrConnectionClosed) {
+		return err
 	}
+	return nil
+}
+
+// PlayerCount возвращает текущее количество игроков в комнате
+func (g *Game) PlayerCount() int {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	return len(g.Players)
 }
 
 func (g *Game) RemovePlayer(id uint) {
 	g.Mutex.Lock()
 	defer g.Mutex.Unlock()
 
+	g.removePlayer(id)
+}
+
+// removePlayer вызывается под g.Mutex
+func (g *Game) removePlayer(id uint) {
 	if player, exists := g.Players[id]; exists {
 		if player.Conn != nil {
 			player.Conn.Close()
 		}
+		g.record(replayEvent{Kind: replayLeave, PlayerID: id})
+		g.scheduler.cancelOwner(playerOwner(id))
+		g.scheduler.cancelOwner(sessionOwner(id))
+		g.anticheat.forget(id)
+		g.inbox.forget(id)
+		g.removeFromOrder(id)
 		delete(g.Players, id)
 		log.Printf("Игрок %d удален", id)
 	}
 }
+
+// addToOrder вставляет игрока в playerOrder с сохранением порядка по ID
+func (g *Game) addToOrder(player *Player) {
+	i := sort.Search(len(g.playerOrder), func(i int) bool { return g.playerOrder[i].ID >= player.ID })
+	g.playerOrder = append(g.playerOrder, nil)
+	copy(g.playerOrder[i+1:], g.playerOrder[i:])
+	g.playerOrder[i] = player
+}
+
+func (g *Game) removeFromOrder(id uint) {
+	for i, player := range g.playerOrder {
+		if player.ID == id {
+			g.playerOrder = append(g.playerOrder[:i], g.playerOrder[i+1:]...)
+			return
+		}
+	}
+}
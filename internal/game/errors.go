@@ -0,0 +1,43 @@
+package game
+
+import "errors"
+
+var (
+	ErrPlayerExists    = errors.New("игрок с таким ID уже существует")
+	ErrSpectatorExists = errors.New("зритель с таким ID уже существует")
+	ErrSpectatorInGame = errors.New("игрок матча не может смотреть матчи как зритель")
+	ErrPlayerSpectates = errors.New("зритель не может войти в игру, пока смотрит матч")
+	ErrRoomFull        = errors.New("комната заполнена")
+	ErrRoomNotFound    = errors.New("комната не найдена")
+	ErrRoomExists      = errors.New("комната с таким ID уже существует")
+	ErrNotRoomOwner    = errors.New("комната создана другим игроком")
+	ErrShuttingDown    = errors.New("сервер останавливается")
+
+	ErrPlayerNotFound     = errors.New("игрок не найден")
+	ErrInvalidResumeToken = errors.New("неверный токен возобновления")
+	ErrInputQueueFull     = errors.New("очередь команд комнаты переполнена")
+	ErrCheatSuspected     = errors.New("игрок отключён античитом")
+	ErrKickCooldown       = errors.New("игрок отключён античитом и пока не может вернуться")
+	ErrPlayerAlive        = errors.New("игрок жив")
+	ErrRespawnTooEarly    = errors.New("слишком рано для возрождения")
+
+	ErrInvalidClassTree = errors.New("некорректное дерево классов")
+	ErrUnknownClass     = errors.New("неизвестный класс")
+	ErrClassLocked      = errors.New("класс недоступен")
+
+	ErrInvalidUpgrades    = errors.New("некорректный каталог улучшений")
+	ErrInvalidObjectKinds = errors.New("некорректный список видов объектов")
+	ErrUnknownUpgrade     = errors.New("неизвестное улучшение")
+	ErrNoSkillPoints      = errors.New("нет очков навыка")
+	ErrUpgradeMaxed       = errors.New("улучшение достигло максимального уровня")
+	ErrStatCapped         = errors.New("стат достиг предела класса")
+
+	ErrReplayNotFound = errors.New("запись матча не найдена")
+	ErrReplayVersion  = errors.New("неподдерживаемая версия записи")
+	ErrReplayDesync   = errors.New("повтор разошёлся с записью")
+	ErrReplayDisabled = errors.New("запись матчей отключена")
+	ErrReplaySpeed    = errors.New("недопустимая скорость повтора")
+
+	// ErrConnectionClosed возвращается Connection.Send после закрытия соединения
+	ErrConnectionClosed = errors.New("соединение закрыто")
+)
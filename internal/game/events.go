@@ -0,0 +1,192 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gameCore/internal/protocol"
+)
+
+// EventKind - вид игрового события
+type EventKind uint8
+
+const (
+	EventBulletFired     EventKind = iota + 1 // Игрок SourceID выпустил пулю BulletID
+	EventDamageDealt                          // SourceID нанёс TargetID урон Amount
+	EventPlayerKilled                         // SourceID убил игрока TargetID
+	EventObjectDestroyed                      // SourceID уничтожил объект TargetID
+	EventLevelUp                              // Игрок TargetID достиг уровня Level
+	EventRespawned                            // Игрок TargetID возродился в X, Y
+)
+
+var eventKindNames = map[EventKind]string{
+	EventBulletFired:     "bullet_fired",
+	EventDamageDealt:     "damage_dealt",
+	EventPlayerKilled:    "player_killed",
+	EventObjectDestroyed: "object_destroyed",
+	EventLevelUp:         "level_up",
+	EventRespawned:       "respawned",
+}
+
+func (k EventKind) String() string {
+	if name, ok := eventKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(k))
+}
+
+func (k EventKind) MarshalJSON() ([]byte, error) {
+	return json.Marshal(k.String())
+}
+
+// Event - событие симуляции для интерфейса клиента: ленты убийств,
+// маркеров попаданий и чисел урона. На саму симуляцию не влияет
+type Event struct {
+	Kind     EventKind `json:"kind"`
+	Tick     uint64    `json:"tick"`
+	SourceID uint      `json:"source_id,omitempty"` // Игрок, вызвавший событие
+	TargetID uint      `json:"target_id,omitempty"` // Игрок или объект, с которым оно произошло
+	Object   bool      `json:"object,omitempty"`    // TargetID - объект, а не игрок
+	BulletID uint      `json:"bullet_id,omitempty"`
+	X        float64   `json:"x"` // Где произошло событие
+	Y        float64   `json:"y"`
+	Amount   float64   `json:"amount,omitempty"` // Нанесённый урон
+	Level    int       `json:"level,omitempty"`
+}
+
+func (e Event) MarshalBinaryTo(w *protocol.Writer) {
+	w.Uint8(uint8(e.Kind))
+	w.Uint32(uint32(e.Tick))
+	w.Uint32(uint32(e.SourceID))
+	w.Uint32(uint32(e.TargetID))
+	w.Bool(e.Object)
+	w.Uint32(uint32(e.BulletID))
+	w.Float32(e.X)
+	w.Float32(e.Y)
+	w.Float32(e.Amount)
+	w.Uint16(uint16(e.Level))
+}
+
+// EventBatch - нагрузка protocol.TypeEvents: события одного клиента за тик
+type EventBatch struct {
+	Events []Event `json:"events"`
+}
+
+func (b EventBatch) MarshalBinaryTo(w *protocol.Writer) {
+	w.Uint16(uint16(len(b.Events)))
+	for _, e := range b.Events {
+		e.MarshalBinaryTo(w)
+	}
+}
+
+// EventHandler получает события комнаты, накопленные с прошлой рассылки.
+// Вызывается из игрового цикла без g.Mutex и не должен блокировать
+type EventHandler func(g *Game, events []Event)
+
+// WithEventHandler подписывает fn на события комнаты. Без подписчиков
+// события не копятся, поэтому повтор матча их не собирает
+func WithEventHandler(fn EventHandler) Option {
+	return func(g *Game) {
+		g.eventHandlers = append(g.eventHandlers, fn)
+	}
+}
+
+// emit добавляет событие текущего тика. Вызывается под g.Mutex
+func (g *Game) emit(e Event) {
+	if len(g.eventHandlers) == 0 {
+		return
+	}
+	e.Tick = g.Tick
+
+	// Таранный урон приходит каждый шаг: урон одной цели от одного
+	// источника за тик складываем в одно событие
+	if e.Kind == EventDamageDealt {
+		for i := len(g.events) - 1; i >= 0 && g.events[i].Tick == e.Tick; i-- {
+			prev := &g.events[i]
+			if prev.Kind == e.Kind && prev.SourceID == e.SourceID && prev.TargetID == e.TargetID && prev.Object == e.Object {
+				prev.Amount += e.Amount
+				prev.X, prev.Y = e.X, e.Y
+				return
+			}
+		}
+	}
+	g.events = append(g.events, e)
+}
+
+// publishEvents отдаёт накопленные события подписчикам. Вызывается из
+// игрового цикла после рассылки снимка, чтобы клиент уже видел участников
+func (g *Game) publishEvents() {
+	g.Mutex.Lock()
+	events := g.events
+	g.events = nil
+	g.Mutex.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	for _, handler := range g.eventHandlers {
+		handler(g, events)
+	}
+}
+
+// RouteEvents раскладывает события по соединениям, которым они нужны.
+// Убийства видны всем, урон - только его участникам, остальное - тем,
+// в чьей зоне видимости оно произошло. Зритель получает события игрока,
+// за которым следит, или все, кроме чужого урона, если смотрит весь мир
+func (g *Game) RouteEvents(events []Event) map[Connection]EventBatch {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+
+	routed := make(map[Connection]EventBatch)
+	deliver := func(conn Connection, e Event) {
+		batch := routed[conn]
+		batch.Events = append(batch.Events, e)
+		routed[conn] = batch
+	}
+
+	for _, e := range events {
+		for _, player := range g.playerOrder {
+			if player.Conn != nil && g.eventRelevant(e, player) {
+				deliver(player.Conn, e)
+			}
+		}
+		for _, spectator := range g.Spectators {
+			if spectator.Conn == nil {
+				continue
+			}
+			if target, ok := g.Players[spectator.Follow]; ok {
+				if g.eventRelevant(e, target) {
+					deliver(spectator.Conn, e)
+				}
+			} else if e.Kind != EventDamageDealt {
+				deliver(spectator.Conn, e)
+			}
+		}
+	}
+	return routed
+}
+
+// eventRelevant сообщает, нужно ли событие игроку. Вызывается под g.Mutex
+func (g *Game) eventRelevant(e Event, player *Player) bool {
+	involved := player.ID == e.SourceID || (!e.Object && player.ID == e.TargetID)
+	switch e.Kind {
+	case EventPlayerKilled:
+		return true
+	case EventDamageDealt:
+		return involved
+	}
+	if involved {
+		return true
+	}
+	dx, dy := e.X-player.X, e.Y-player.Y
+	return dx*dx+dy*dy <= g.ViewRadius*g.ViewRadius
+}
+
+// awardXP начисляет опыт и сообщает о повышении уровня. Вызывается под g.Mutex
+func (g *Game) awardXP(player *Player, xp int) {
+	level := player.Level
+	player.GainXP(xp)
+	if player.Level > level {
+		g.emit(Event{Kind: EventLevelUp, TargetID: player.ID, X: player.X, Y: player.Y, Level: player.Level})
+	}
+}
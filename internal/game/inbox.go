@@ -0,0 +1,225 @@
+package game
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// inbox принимает ввод от читателей соединений, не блокируя их.
+// Движение и прицел устаревают каждый тик, поэтому от игрока хранится
+// только последнее сообщение. Команды (улучшения, выбор класса, возрождение)
+// разовые и не должны теряться, поэтому идут отдельной очередью по порядку
+type inbox struct {
+	mu       sync.Mutex
+	moves    map[uint]PlayerInput
+	commands []PlayerInput
+	state    map[uint]PlayerInputData // Последний принятый ввод, к которому применяются частичные обновления
+}
+
+func newInbox() *inbox {
+	return &inbox{moves: make(map[uint]PlayerInput), state: make(map[uint]PlayerInputData)}
+}
+
+// InputField - часть ввода, которую меняет сообщение клиента
+type InputField uint8
+
+const (
+	InputMove  InputField = 1 << iota // Up, Down, Left, Right
+	InputAim                          // Angle
+	InputShoot                        // Shoot
+
+	InputAll = InputMove | InputAim | InputShoot
+)
+
+// HasCommand сообщает, несёт ли сообщение разовую команду
+func (d PlayerInputData) HasCommand() bool {
+	return d.UpgradeStat != "" || d.Class != "" || d.Respawn
+}
+
+// UpdateInput меняет только поля fields последнего ввода игрока, остальные
+// остаются прежними. Команды, номер и подтверждения берутся из input как есть.
+// Ошибки те же, что у SubmitInput
+func (g *Game) UpdateInput(input PlayerInput, fields InputField) error {
+	g.inbox.mu.Lock()
+	merged := g.inbox.state[input.ID]
+	g.inbox.mu.Unlock()
+
+	if fields&InputMove != 0 {
+		merged.Up, merged.Down = input.Input.Up, input.Input.Down
+		merged.Left, merged.Right = input.Input.Left, input.Input.Right
+	}
+	if fields&InputAim != 0 {
+		merged.Angle = input.Input.Angle
+	}
+	if fields&InputShoot != 0 {
+		merged.Shoot = input.Input.Shoot
+	}
+	merged.UpgradeStat = input.Input.UpgradeStat
+	merged.Class = input.Input.Class
+	merged.Respawn = input.Input.Respawn
+	merged.Ack = input.Input.Ack
+	merged.ViewTick = input.Input.ViewTick
+	merged.Seq = input.Input.Seq
+
+	input.Input = merged
+	return g.SubmitInput(input)
+}
+
+// SubmitInput проверяет ввод античитом и ставит его в очередь до следующего
+// тика, никогда не блокируя. Возвращает ErrCheatSuspected, если игрока пора
+// отключить, и ErrInputQueueFull, если очередь команд переполнена
+func (g *Game) SubmitInput(input PlayerInput) error {
+	if report, ok := g.inspect(&input, time.Now()); ok {
+		if g.onCheat != nil {
+			g.onCheat(g, report)
+		}
+		if report.Kicked {
+			return ErrCheatSuspected
+		}
+	}
+
+	in := g.inbox
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if input.Input.HasCommand() {
+		if len(in.commands) >= MaxInputQueue {
+			return ErrInputQueueFull
+		}
+		in.commands = append(in.commands, PlayerInput{
+			ID: input.ID,
+			Input: PlayerInputData{
+				UpgradeStat: input.Input.UpgradeStat,
+				Class:       input.Input.Class,
+				Respawn:     input.Input.Respawn,
+				Seq:         input.Input.Seq,
+			},
+		})
+	}
+
+	move := input
+	move.Input.UpgradeStat = ""
+	move.Input.Class = ""
+	move.Input.Respawn = false
+	in.state[input.ID] = move.Input
+	// Выстрел из вытесненного сообщения не теряется, даже если в последнем
+	// кнопку уже отпустили
+	if prev, ok := in.moves[input.ID]; ok {
+		move.Shot = prev.Shot || prev.Input.Shoot || move.Shot
+	}
+	in.moves[input.ID] = move
+	return nil
+}
+
+// forget выбрасывает ввод игрока, пришедший со старого соединения
+func (in *inbox) forget(id uint) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	delete(in.moves, id)
+	delete(in.state, id)
+	kept := in.commands[:0]
+	for _, command := range in.commands {
+		if command.ID != id {
+			kept = append(kept, command)
+		}
+	}
+	in.commands = kept
+}
+
+// drainInbox применяет накопленные команды и последний ввод каждого игрока.
+// Вызывается из игрового цикла перед шагами симуляции
+func (g *Game) drainInbox() {
+	in := g.inbox
+	in.mu.Lock()
+	commands := in.commands
+	in.commands = nil
+	moves := make([]PlayerInput, 0, len(in.moves))
+	for id, move := range in.moves {
+		moves = append(moves, move)
+		delete(in.moves, id)
+	}
+	in.mu.Unlock()
+
+	// Порядок игроков не должен зависеть от обхода карты
+	sort.Slice(moves, func(i, j int) bool { return moves[i].ID < moves[j].ID })
+
+	for _, command := range commands {
+		g.collectCommand(command)
+	}
+	for _, move := range moves {
+		g.collectInput(move)
+	}
+}
+
+// collectCommand выполняет разовую команду игрока. Повторно присланная
+// команда с тем же номером не выполняется второй раз
+func (g *Game) collectCommand(input PlayerInput) {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	player, ok := g.Players[input.ID]
+	if !ok || player.disconnected {
+		return
+	}
+	if input.Input.Seq != 0 {
+		if input.Input.Seq <= player.lastCommandSeq {
+			return
+		}
+		player.lastCommandSeq = input.Input.Seq
+	}
+	g.record(replayEvent{Kind: replayCommand, PlayerID: input.ID, Input: input.Input})
+
+	if input.Input.UpgradeStat != "" {
+		log.Printf("Стат для апдейта %s", input.Input.UpgradeStat)
+		g.handleUpgrade(player, input.Input.UpgradeStat)
+	}
+	if input.Input.Class != "" && input.Input.Class != player.class.Name {
+		if err := g.chooseClass(player, input.Input.Class); err != nil {
+			log.Printf("Игрок %d не может выбрать класс %s: %v", player.ID, input.Input.Class, err)
+		}
+	}
+	if input.Input.Respawn {
+		if err := g.requestRespawn(player); err != nil {
+			log.Printf("Игрок %d не может возродиться: %v", player.ID, err)
+		}
+	}
+}
+
+// CanRespawn сообщает, примет ли симуляция просьбу игрока возродиться.
+// Позволяет ответить клиенту ошибкой, не дожидаясь тика
+func (g *Game) CanRespawn(id uint) error {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+
+	player, exists := g.Players[id]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+	return g.respawnAllowed(player)
+}
+
+// respawnAllowed вызывается под g.Mutex
+func (g *Game) respawnAllowed(player *Player) error {
+	if player.Alive {
+		return ErrPlayerAlive
+	}
+	if g.Tick < player.diedTick+g.durationToTicks(RespawnMinDelay) {
+		return ErrRespawnTooEarly
+	}
+	return nil
+}
+
+// requestRespawn возрождает погибшего игрока раньше RespawnTime. Вызывается
+// под g.Mutex; запланированное возрождение отменяется
+func (g *Game) requestRespawn(player *Player) error {
+	if err := g.respawnAllowed(player); err != nil {
+		return err
+	}
+	// Пока игрок мёртв, за ним числится только отложенный респавн
+	g.scheduler.cancelOwner(playerOwner(player.ID))
+	g.respawnPlayer(player)
+	return nil
+}
@@ -0,0 +1,126 @@
+package game
+
+import "gameCore/internal/protocol"
+
+const (
+	DefaultViewRadius       = 1000.0 // Радиус видимости клиента по умолчанию
+	DefaultInterestCellSize = 200.0  // Размер ячейки сетки интереса
+)
+
+type entityKind uint8
+
+const (
+	entityPlayer entityKind = iota
+	entityBullet
+	entityObject
+)
+
+type entityRef struct {
+	kind entityKind
+	id   uint
+}
+
+// WithInterest задаёт радиус видимости и размер ячейки сетки интереса
+func WithInterest(viewRadius, cellSize float64) Option {
+	return func(g *Game) {
+		if viewRadius > 0 {
+			g.ViewRadius = viewRadius
+		}
+		if cellSize > 0 {
+			g.InterestCellSize = cellSize
+		}
+	}
+}
+
+// interestGrid раскладывает собранный мир по ячейкам один раз за рассылку
+func (g *Game) interestGrid(world worldState) *spatialGrid[entityRef] {
+	grid := newSpatialGrid[entityRef](g.InterestCellSize)
+	for id, p := range world.Players {
+		grid.Insert(p.X, p.Y, entityRef{kind: entityPlayer, id: id})
+	}
+	for id, b := range world.Bullets {
+		grid.Insert(b.X, b.Y, entityRef{kind: entityBullet, id: id})
+	}
+	for id, o := range world.Objects {
+		grid.Insert(o.X, o.Y, entityRef{kind: entityObject, id: id})
+	}
+	return grid
+}
+
+// visibleFrom оставляет в мире только сущности в радиусе обзора точки.
+// Клиент не получает ничего за пределами обзора, что заодно лишает смысла мапхак
+func (world worldState) visibleFrom(grid *spatialGrid[entityRef], x, y, radius float64) worldState {
+	visible := worldState{
+		Players: make(map[uint]playerState),
+		Bullets: make(map[uint]bulletState),
+		Objects: make(map[uint]objectState),
+	}
+
+	radiusSq := radius * radius
+	grid.Query(x, y, radius, func(ex, ey float64, ref entityRef) {
+		dx, dy := ex-x, ey-y
+		if dx*dx+dy*dy > radiusSq {
+			return
+		}
+		switch ref.kind {
+		case entityPlayer:
+			visible.Players[ref.id] = world.Players[ref.id]
+		case entityBullet:
+			visible.Bullets[ref.id] = world.Bullets[ref.id]
+		case entityObject:
+			visible.Objects[ref.id] = world.Objects[ref.id]
+		}
+	})
+	return visible
+}
+
+// interestMessage - нагрузка protocol.TypeInterest: игроки и объекты,
+// вошедшие в зону видимости клиента или покинувшие её. Пули живут
+// недолго и приходят только в снимках
+type interestMessage struct {
+	Entered interestEntities `json:"entered"`
+	Left    interestEntities `json:"left"`
+}
+
+type interestEntities struct {
+	Players []uint `json:"players,omitempty"`
+	Objects []uint `json:"objects,omitempty"`
+}
+
+func (m interestMessage) empty() bool {
+	return len(m.Entered.Players) == 0 && len(m.Entered.Objects) == 0 &&
+		len(m.Left.Players) == 0 && len(m.Left.Objects) == 0
+}
+
+func (m interestMessage) MarshalBinaryTo(w *protocol.Writer) {
+	writeIDs(w, m.Entered.Players)
+	writeIDs(w, m.Entered.Objects)
+	writeIDs(w, m.Left.Players)
+	writeIDs(w, m.Left.Objects)
+}
+
+// updateInterest сравнивает видимое сейчас с видимым в прошлой рассылке
+func (v *clientView) updateInterest(visible worldState) interestMessage {
+	var msg interestMessage
+
+	msg.Entered.Players, msg.Left.Players = interestChanges(v.visiblePlayers, visible.Players)
+	msg.Entered.Objects, msg.Left.Objects = interestChanges(v.visibleObjects, visible.Objects)
+
+	v.visiblePlayers = visible.Players
+	v.visibleObjects = visible.Objects
+	return msg
+}
+
+func interestChanges[T any](prev, current map[uint]T) (entered, left []uint) {
+	for _, id := range sortedIDs(current) {
+		if _, ok := prev[id]; !ok {
+			entered = append(entered, id)
+		}
+	}
+	for _, id := range sortedIDs(prev) {
+		if _, ok := current[id]; !ok {
+			left = append(left, id)
+		}
+	}
+	return entered, left
+}
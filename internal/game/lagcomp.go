@@ -0,0 +1,130 @@
+package game
+
+import (
+	"math"
+	"time"
+)
+
+const DefaultMaxRewind = 200 * time.Millisecond // Предел отката по умолчанию
+
+// positionSample - положение игрока на конец тика
+type positionSample struct {
+	tick  uint64
+	x, y  float64
+	alive bool
+}
+
+// positionHistory - кольцевой буфер последних положений игрока
+type positionHistory struct {
+	samples []positionSample
+	next    int
+}
+
+func newPositionHistory(size int) *positionHistory {
+	return &positionHistory{samples: make([]positionSample, size)}
+}
+
+func (h *positionHistory) record(tick uint64, x, y float64, alive bool) {
+	h.samples[h.next] = positionSample{tick: tick, x: x, y: y, alive: alive}
+	h.next = (h.next + 1) % len(h.samples)
+}
+
+func (h *positionHistory) at(tick uint64) (positionSample, bool) {
+	for _, sample := range h.samples {
+		if sample.tick == tick && tick != 0 {
+			return sample, true
+		}
+	}
+	return positionSample{}, false
+}
+
+// WithMaxRewind ограничивает, насколько далеко в прошлое можно откатить цели
+func WithMaxRewind(maxRewind time.Duration) Option {
+	return func(g *Game) {
+		if maxRewind > 0 {
+			g.MaxRewind = maxRewind
+		}
+	}
+}
+
+// rewindTicks - окно отката в тиках
+func (g *Game) rewindTicks() uint64 {
+	return uint64(g.MaxRewind / g.TickRate)
+}
+
+// historySize - сколько тиков истории нужно хранить на игрока
+func (g *Game) historySize() int {
+	return int(g.rewindTicks()) + 2
+}
+
+// recordPositions запоминает положения игроков после шага. Номер тика
+// совпадает с тем, что клиенты получают в снимках
+func (g *Game) recordPositions() {
+	for _, player := range g.Players {
+		player.history.record(g.Tick, player.X, player.Y, player.Alive)
+	}
+}
+
+// rewindHitTest проверяет выстрел по целям в том положении, в котором их
+// видел стрелок на тике viewTick. Путь, который пуля прошла бы за время
+// отката, проверяется сразу, и пуля переносится в его конец. Объекты и
+// нынешние положения игроков на этом пути проверяются обычной проверкой
+// коллизий: попадание в них раньше откатанной цели останавливает пулю.
+// Возвращает false, если пуля попала. Вызывается под g.Mutex
+func (g *Game) rewindHitTest(bullet *Bullet, viewTick uint64, dt float64) bool {
+	if viewTick == 0 || viewTick >= g.Tick {
+		return true
+	}
+
+	// Клиент не может откатить дальше разрешённого окна
+	oldest := uint64(0)
+	if g.Tick > g.rewindTicks() {
+		oldest = g.Tick - g.rewindTicks()
+	}
+	if viewTick < oldest {
+		viewTick = oldest
+	}
+
+	rewound := g.Tick - viewTick
+	distance := bullet.Speed * dt * float64(rewound)
+	fromX, fromY := bullet.X, bullet.Y
+	toX := fromX + distance*math.Cos(bullet.Angle)
+	toY := fromY + distance*math.Sin(bullet.Angle)
+
+	hitT := math.Inf(1)
+	var target *Player
+	reach := PlayerRadius + bullet.Radius
+
+	for _, player := range g.playerOrder {
+		if player.ID == bullet.OwnerID {
+			continue
+		}
+		sample, ok := player.history.at(viewTick)
+		if !ok || !sample.alive || !player.Alive {
+			continue
+		}
+		if t, ok := segmentCircleHit(fromX, fromY, toX, toY, sample.x, sample.y, reach); ok && t < hitT {
+			hitT, target = t, player
+		}
+	}
+
+	// Путь пули заканчивается на откатанной цели или в конце окна отката
+	bullet.X, bullet.Y = toX, toY
+	if target != nil {
+		bullet.X = fromX + (toX-fromX)*hitT
+		bullet.Y = fromY + (toY-fromY)*hitT
+	}
+	if !g.checkBulletCollisions(bullet, fromX, fromY) {
+		return false
+	}
+
+	if target == nil {
+		// Промах: пуля продолжает путь с того места, где была бы у стрелка
+		bullet.SpawnTick = viewTick
+		return true
+	}
+
+	bullet.Active = false
+	target.TakeDamage(bullet.Damage, g, bullet.OwnerID)
+	return false
+}
@@ -0,0 +1,42 @@
+package game
+
+import (
+	"testing"
+)
+
+// Игрок, вставший на пути пули на этом шаге, закрывает собой откатанную
+// цель, хотя в сетке коллизий прошлого шага его там не было
+func TestRewindStopsAtPlayerMovedThisStep(t *testing.T) {
+	g := NewGame(WithSeed(1))
+	g.CleanupObjects()
+	for _, id := range []uint{1, 2, 3} {
+		if err := g.AddPlayer(id, nil); err != nil {
+			t.Fatal(err)
+		}
+		g.Players[id].protected = false
+	}
+	shooter, target, blocker := g.Players[1], g.Players[2], g.Players[3]
+	shooter.X, shooter.Y = 1000, 1000
+	target.X, target.Y = 1300, 1000
+	blocker.X, blocker.Y = 1100, 1500
+	shooter.Stats["bullet_speed"] = 20000
+
+	g.step()
+	viewTick := g.Tick
+	for i := 0; i < 5; i++ {
+		g.step()
+	}
+
+	// Блокирующий игрок переместился после прошлой перестройки сеток
+	blocker.X, blocker.Y = 1100, 1000
+	targetHealth, blockerHealth := target.Stats["health"], blocker.Stats["health"]
+	shooter.input = PlayerInputData{Shoot: true, Angle: 0, ViewTick: viewTick}
+	g.step()
+
+	if blocker.Stats["health"] >= blockerHealth {
+		t.Fatal("пуля прошла сквозь игрока, вставшего на её пути")
+	}
+	if target.Stats["health"] != targetHealth {
+		t.Fatal("пуля попала в откатанную цель сквозь блокирующего игрока")
+	}
+}
@@ -0,0 +1,88 @@
+package game
+
+import (
+	"sort"
+
+	"gameCore/internal/protocol"
+)
+
+// levelUpMessage - нагрузка protocol.TypeLevelUp
+type levelUpMessage struct {
+	SkillPoints int      `json:"skill_points"`
+	Level       int      `json:"level"`
+	NewLvlExp   int      `json:"new_lvl_exp"`
+	Classes     []string `json:"classes,omitempty"` // Специализации, доступные для выбора
+}
+
+func (m levelUpMessage) MarshalBinaryTo(w *protocol.Writer) {
+	w.Uint16(uint16(m.SkillPoints))
+	w.Uint16(uint16(m.Level))
+	w.Uint32(uint32(m.NewLvlExp))
+	w.Uint8(uint8(len(m.Classes)))
+	for _, class := range m.Classes {
+		w.String(class)
+	}
+}
+
+// upgradeMessage - нагрузка protocol.TypeUpgrade
+type upgradeMessage struct {
+	SkillPoints int                `json:"skill_points"`
+	Stats       map[string]float64 `json:"stats"`
+	Levels      map[string]int     `json:"levels"` // Уровни купленных улучшений
+}
+
+func (m upgradeMessage) MarshalBinaryTo(w *protocol.Writer) {
+	w.Uint16(uint16(m.SkillPoints))
+	writeStats(w, m.Stats)
+	writeLevels(w, m.Levels)
+}
+
+// standing - строка итоговой таблицы матча
+type standing struct {
+	ID    uint `json:"id"`
+	Level int  `json:"level"`
+	XP    int  `json:"xp"`
+}
+
+// matchEndMessage - нагрузка protocol.TypeMatchEnd
+type matchEndMessage struct {
+	Standings []standing `json:"standings"`
+}
+
+func (m matchEndMessage) MarshalBinaryTo(w *protocol.Writer) {
+	w.Uint16(uint16(len(m.Standings)))
+	for _, s := range m.Standings {
+		w.Uint32(uint32(s.ID))
+		w.Uint16(uint16(s.Level))
+		w.Uint32(uint32(s.XP))
+	}
+}
+
+func writeLevels(w *protocol.Writer, levels map[string]int) {
+	keys := make([]string, 0, len(levels))
+	for key := range levels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w.Uint8(uint8(len(keys)))
+	for _, key := range keys {
+		w.String(key)
+		w.Uint8(uint8(levels[key]))
+	}
+}
+
+// writeStats пишет статы в порядке ключей, чтобы кадр был детерминирован
+func writeStats(w *protocol.Writer, stats map[string]float64) {
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w.Uint8(uint8(len(keys)))
+	for _, key := range keys {
+		w.String(key)
+		w.Float32(stats[key])
+	}
+}
@@ -0,0 +1,121 @@
+package game
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"gameCore/internal/config"
+)
+
+// DefaultObjectKinds - виды объектов по умолчанию, от частых и слабых к редким.
+// Пределы в сумме больше MaxObjects, чтобы состав карты решал вес
+var DefaultObjectKinds = []config.ObjectKindConfig{
+	{Name: "square", Health: 10, XP: 10, Radius: 12, Weight: 60, MaxCount: 24},
+	{Name: "triangle", Health: 30, XP: 25, Radius: 14, Weight: 25, MaxCount: 12, DriftSpeed: 10},
+	{Name: "pentagon", Health: 100, XP: 130, Radius: ObjectRadius, Weight: 12, MaxCount: 6, DriftSpeed: 5},
+	{Name: "alpha_pentagon", Health: 3000, XP: 3000, Radius: 60, Weight: 0.5, MaxCount: 1, DriftSpeed: 2},
+}
+
+// objectKinds - реестр видов объектов комнаты со взвешенным выбором
+type objectKinds struct {
+	kinds       []config.ObjectKindConfig
+	totalWeight float64
+	maxRadius   float64 // Наибольший радиус для запросов к сетке коллизий
+}
+
+func newObjectKinds(defs []config.ObjectKindConfig) (*objectKinds, error) {
+	registry := &objectKinds{kinds: make([]config.ObjectKindConfig, 0, len(defs))}
+	seen := make(map[string]bool, len(defs))
+
+	for _, def := range defs {
+		if def.Name == "" || def.Health <= 0 || def.Weight < 0 || def.MaxCount < 0 {
+			return nil, fmt.Errorf("%w: вид %q задан не полностью", ErrInvalidObjectKinds, def.Name)
+		}
+		if seen[def.Name] {
+			return nil, fmt.Errorf("%w: вид %s объявлен дважды", ErrInvalidObjectKinds, def.Name)
+		}
+		seen[def.Name] = true
+
+		if def.Radius <= 0 {
+			def.Radius = ObjectRadius
+		}
+		registry.kinds = append(registry.kinds, def)
+		registry.totalWeight += def.Weight
+		registry.maxRadius = math.Max(registry.maxRadius, def.Radius)
+	}
+
+	if registry.totalWeight <= 0 {
+		return nil, fmt.Errorf("%w: суммарный вес равен нулю", ErrInvalidObjectKinds)
+	}
+	return registry, nil
+}
+
+// WithObjectKinds задаёт виды объектов комнаты. Некорректный список
+// отбрасывается, остаются виды по умолчанию
+func WithObjectKinds(defs []config.ObjectKindConfig) Option {
+	return func(g *Game) {
+		if len(defs) == 0 {
+			return
+		}
+		registry, err := newObjectKinds(defs)
+		if err != nil {
+			log.Printf("Виды объектов комнаты %s не загружены: %v", g.ID, err)
+			return
+		}
+		g.objectKinds = registry
+	}
+}
+
+// target возвращает, больше скольких объектов вида на карте не держать.
+// Без явного MaxCount вид получает долю maxObjects пропорционально весу
+func (k *objectKinds) target(kind *config.ObjectKindConfig, maxObjects int) int {
+	if kind.MaxCount > 0 {
+		return kind.MaxCount
+	}
+	return int(math.Round(float64(maxObjects) * kind.Weight / k.totalWeight))
+}
+
+// pick выбирает вид для нового объекта по значению roll в [0, 1)
+// пропорционально весу среди видов, не достигших предела и не
+// исключённых skip. nil - выбирать не из чего
+func (k *objectKinds) pick(counts map[string]int, skip map[string]bool, maxObjects int, roll float64) *config.ObjectKindConfig {
+	var total float64
+	for i := range k.kinds {
+		if k.eligible(&k.kinds[i], counts, skip, maxObjects) {
+			total += k.kinds[i].Weight
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	roll *= total
+	var last *config.ObjectKindConfig
+	for i := range k.kinds {
+		kind := &k.kinds[i]
+		if !k.eligible(kind, counts, skip, maxObjects) {
+			continue
+		}
+		last = kind
+		roll -= kind.Weight
+		if roll < 0 {
+			return kind
+		}
+	}
+	return last
+}
+
+func (k *objectKinds) eligible(kind *config.ObjectKindConfig, counts map[string]int, skip map[string]bool, maxObjects int) bool {
+	return kind.Weight > 0 && !skip[kind.Name] && counts[kind.Name] < k.target(kind, maxObjects)
+}
+
+func mustObjectKinds(defs []config.ObjectKindConfig) *objectKinds {
+	registry, err := newObjectKinds(defs)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+var defaultObjectKinds = mustObjectKinds(DefaultObjectKinds)
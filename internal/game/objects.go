@@ -2,112 +2,106 @@ package game
 
 import (
 	"log"
+	"math"
 	"math/rand"
-	"sync/atomic"
-	"time"
-)
-
-var objectIDCounter uint32
 
-func NewObjectID() uint {
-	return uint(atomic.AddUint32(&objectIDCounter, 1))
-}
+	"gameCore/internal/config"
+)
 
 type Object struct {
-	ID           uint
-	X, Y         float64
-	Type         string
-	Health       int
-	XP           int
-	Active       bool
-	respawnTimer *time.Timer
+	ID          uint
+	X, Y        float64
+	Type        string // Имя вида объекта из реестра комнаты
+	Health      float64
+	MaxHealth   float64
+	XP          int
+	Radius      float64
+	Active      bool
+	respawnTick uint64 // Тик, после которого уничтоженный объект возрождается
+	driftSpeed  float64
+	driftX      float64 // Скорость дрейфа по осям, пикселей в секунду
+	driftY      float64
 }
 
-func (g *Game) AddObject() {
-	g.Mutex.Lock()
-	defer g.Mutex.Unlock()
-
-	if len(g.Objects) >= g.MaxObjects {
-		return
-	}
-
+// spawnObject создаёт объект вида kind в точке (x, y). Вызывается под g.Mutex
+func (g *Game) spawnObject(kind *config.ObjectKindConfig, x, y float64) {
+	g.nextObjectID++
 	object := &Object{
-		ID:     NewObjectID(),
-		X:      float64(rand.Intn(MaxX-MinX) + MinX),
-		Y:      float64(rand.Intn(MaxY-MinY) + MinY),
-		Health: 100,
-		XP:     50,
-		Active: true,
+		ID:         g.nextObjectID,
+		X:          x,
+		Y:          y,
+		Type:       kind.Name,
+		Health:     float64(kind.Health),
+		MaxHealth:  float64(kind.Health),
+		XP:         kind.XP,
+		Radius:     kind.Radius,
+		Active:     true,
+		driftSpeed: kind.DriftSpeed,
 	}
+	object.randomizeDrift(g.rng)
 
 	g.Objects = append(g.Objects, object)
-	log.Printf("Создан объект %d (%.1f, %.1f)", object.ID, object.X, object.Y)
+	log.Printf("Создан объект %d (%s) (%.1f, %.1f)", object.ID, object.Type, object.X, object.Y)
 }
 
-func (o *Object) Destroy(g *Game, attackerID uint) {
-	g.Mutex.Lock()
-	defer g.Mutex.Unlock()
-
-	o.Active = false
-	if attacker, exists := g.Players[attackerID]; exists {
-		attacker.GainXP(o.XP)
+// randomizeDrift выбирает случайное направление медленного дрейфа
+func (o *Object) randomizeDrift(rng *rand.Rand) {
+	if o.driftSpeed <= 0 {
+		return
 	}
-
-	o.respawnTimer = time.AfterFunc(g.RespawnDelay, func() {
-		g.Mutex.Lock()
-		o.Respawn(g)
-		g.Mutex.Unlock()
-	})
+	angle := rng.Float64() * 2 * math.Pi
+	o.driftX = math.Cos(angle) * o.driftSpeed
+	o.driftY = math.Sin(angle) * o.driftSpeed
 }
 
-func (o *Object) Respawn(g *Game) {
-	o.X = float64(rand.Intn(MaxX))
-	o.Y = float64(rand.Intn(MaxY))
-	o.Active = true
-	log.Printf("Объект %d восстановлен", o.ID)
-}
-
-func (g *Game) CheckObjects() {
-	activeCount := 0
+// updateObjects сдвигает дрейфующие объекты, отражая их от границ карты.
+// Вызывается из шага симуляции
+func (g *Game) updateObjects(dt float64) {
 	for _, obj := range g.Objects {
-		if obj.Active {
-			activeCount++
+		if !obj.Active || (obj.driftX == 0 && obj.driftY == 0) {
+			continue
 		}
-	}
 
-	if activeCount < g.MaxObjects {
-		g.AddObject()
+		obj.X += obj.driftX * dt
+		obj.Y += obj.driftY * dt
+		if obj.X < MinX || obj.X > MaxX {
+			obj.driftX = -obj.driftX
+			obj.X = math.Max(MinX, math.Min(MaxX, obj.X))
+		}
+		if obj.Y < MinY || obj.Y > MaxY {
+			obj.driftY = -obj.driftY
+			obj.Y = math.Max(MinY, math.Min(MaxY, obj.Y))
+		}
 	}
 }
 
-func (g *Game) checkBulletObjectCollisions(bullet *Bullet) bool {
-	g.Mutex.RLock()
-	defer g.Mutex.RUnlock()
-
-	if !bullet.Active {
-		return false
+// Destroy вызывается под g.Mutex из шага симуляции
+func (o *Object) Destroy(g *Game, attackerID uint) {
+	o.Active = false
+	g.emit(Event{Kind: EventObjectDestroyed, SourceID: attackerID, TargetID: o.ID, Object: true, X: o.X, Y: o.Y})
+	if attacker, exists := g.Players[attackerID]; exists {
+		g.awardXP(attacker, o.XP)
 	}
 
-	for _, obj := range g.Objects {
-		if !obj.Active {
-			continue
-		}
+	// Возрождением занимается контроллер популяции в шаге симуляции
+	o.respawnTick = g.Tick + g.durationToTicks(g.RespawnDelay)
+}
 
-		dx := bullet.X - obj.X
-		dy := bullet.Y - obj.Y
-		if dx*dx+dy*dy < (ObjectRadius+BulletRadius)*(ObjectRadius+BulletRadius) {
-			obj.ObjectTakeDamage(int(bullet.Damage), g, bullet.OwnerID)
-			bullet.Active = false
-			return false
-		}
-	}
-	return true
+// Respawn возвращает объект на карту в точке (x, y)
+func (o *Object) Respawn(x, y float64, rng *rand.Rand) {
+	o.X = x
+	o.Y = y
+	o.Health = o.MaxHealth
+	o.randomizeDrift(rng)
+	o.Active = true
+	log.Printf("Объект %d восстановлен", o.ID)
 }
 
-func (o *Object) ObjectTakeDamage(damage int, game *Game, attackerID uint) {
+// ObjectTakeDamage наносит урон без округления: множители классов дают
+// дробный урон, а таранный приходит малыми долями каждый шаг
+func (o *Object) ObjectTakeDamage(damage float64, game *Game, attackerID uint) {
 	o.Health -= damage
-	log.Printf("Игрок %d получил %d урона от %d. Осталось здоровья: %d",
-		o.ID, damage, attackerID, o.Health)
+	game.emit(Event{Kind: EventDamageDealt, SourceID: attackerID, TargetID: o.ID, Object: true, X: o.X, Y: o.Y, Amount: damage})
 
 	if o.Health <= 0 {
 		o.Destroy(game, attackerID)
@@ -115,10 +109,5 @@ func (o *Object) ObjectTakeDamage(damage int, game *Game, attackerID uint) {
 }
 
 func (g *Game) CleanupObjects() {
-	for _, obj := range g.Objects {
-		if obj.respawnTimer != nil {
-			obj.respawnTimer.Stop()
-		}
-	}
 	g.Objects = nil
 }
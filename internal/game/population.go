@@ -0,0 +1,167 @@
+package game
+
+import (
+	"log"
+	"math"
+
+	"gameCore/internal/config"
+)
+
+const (
+	DefaultSpawnPlayerDistance = 150.0 // Объект не появляется ближе к игроку
+	DefaultSpawnObjectDistance = 10.0  // Зазор между краями объектов
+	PopulationInterval         = 10    // Шагов между проверками популяции
+	MaxSpawnsPerCheck          = 3     // Сколько объектов появляется за проверку, чтобы не было всплесков
+	SpawnAttempts              = 10    // Попыток найти свободную точку
+)
+
+// spawnZone - зона появления с множеством разрешённых видов
+type spawnZone struct {
+	config.SpawnZoneConfig
+	kinds map[string]bool // nil - любые виды
+}
+
+func (z *spawnZone) allows(kind string) bool {
+	return z.kinds == nil || z.kinds[kind]
+}
+
+// population поддерживает число объектов каждого вида из шага симуляции:
+// создаёт недостающие и возрождает уничтоженные, когда подошёл их тик
+type population struct {
+	zones          []spawnZone
+	playerDistance float64
+	objectDistance float64
+}
+
+func newPopulation(zones []config.SpawnZoneConfig, playerDistance, objectDistance float64) *population {
+	p := &population{
+		playerDistance: DefaultSpawnPlayerDistance,
+		objectDistance: DefaultSpawnObjectDistance,
+	}
+	if playerDistance > 0 {
+		p.playerDistance = playerDistance
+	}
+	if objectDistance > 0 {
+		p.objectDistance = objectDistance
+	}
+
+	for _, zone := range zones {
+		if zone.Weight <= 0 || zone.MaxX <= zone.MinX || zone.MaxY <= zone.MinY {
+			log.Printf("Пропущена некорректная зона появления %+v", zone)
+			continue
+		}
+		z := spawnZone{SpawnZoneConfig: zone}
+		if len(zone.Kinds) > 0 {
+			z.kinds = make(map[string]bool, len(zone.Kinds))
+			for _, kind := range zone.Kinds {
+				z.kinds[kind] = true
+			}
+		}
+		p.zones = append(p.zones, z)
+	}
+
+	if len(p.zones) == 0 {
+		p.zones = []spawnZone{{SpawnZoneConfig: config.SpawnZoneConfig{
+			MinX: MinX, MinY: MinY, MaxX: MaxX, MaxY: MaxY, Weight: 1,
+		}}}
+	}
+	return p
+}
+
+// WithSpawnZones задаёт зоны появления объектов и минимальные расстояния
+func WithSpawnZones(zones []config.SpawnZoneConfig, playerDistance, objectDistance float64) Option {
+	return func(g *Game) {
+		g.population = newPopulation(zones, playerDistance, objectDistance)
+	}
+}
+
+// maintainPopulation вызывается под g.Mutex. При fill карта заполняется
+// целиком, иначе за вызов появляется не больше MaxSpawnsPerCheck объектов
+func (g *Game) maintainPopulation(fill bool) {
+	counts := make(map[string]int, len(g.objectKinds.kinds))
+	for _, obj := range g.Objects {
+		// Уничтоженный объект ждёт возрождения и занимает место своего вида
+		counts[obj.Type]++
+		if !obj.Active && g.Tick >= obj.respawnTick {
+			if x, y, ok := g.findSpawnPoint(obj.Type, obj.Radius); ok {
+				obj.Respawn(x, y, g.rng)
+			}
+		}
+	}
+
+	// Вид каждого нового объекта выбирается по весу, поэтому редкие виды
+	// занимают места, только если им выпал бросок
+	spawned := 0
+	noRoom := make(map[string]bool) // Виды, которым не нашлось места за вызов
+	for len(g.Objects) < g.MaxObjects && (fill || spawned < MaxSpawnsPerCheck) {
+		kind := g.objectKinds.pick(counts, noRoom, g.MaxObjects, g.rng.Float64())
+		if kind == nil {
+			return
+		}
+		x, y, ok := g.findSpawnPoint(kind.Name, kind.Radius)
+		if !ok {
+			noRoom[kind.Name] = true
+			continue
+		}
+		g.spawnObject(kind, x, y)
+		counts[kind.Name]++
+		spawned++
+	}
+}
+
+// findSpawnPoint ищет точку во взвешенно выбранной зоне не ближе заданных
+// расстояний к живым игрокам и активным объектам
+func (g *Game) findSpawnPoint(kind string, radius float64) (float64, float64, bool) {
+	p := g.population
+
+	var totalWeight float64
+	for i := range p.zones {
+		if p.zones[i].allows(kind) {
+			totalWeight += p.zones[i].Weight
+		}
+	}
+	if totalWeight == 0 {
+		return 0, 0, false
+	}
+
+	for attempt := 0; attempt < SpawnAttempts; attempt++ {
+		zone := p.pickZone(kind, g.rng.Float64()*totalWeight)
+		x := zone.MinX + g.rng.Float64()*(zone.MaxX-zone.MinX)
+		y := zone.MinY + g.rng.Float64()*(zone.MaxY-zone.MinY)
+		if g.spawnPointFree(x, y, radius) {
+			return x, y, true
+		}
+	}
+	return 0, 0, false
+}
+
+// pickZone выбирает зону по значению roll в [0, суммарный вес)
+func (p *population) pickZone(kind string, roll float64) *spawnZone {
+	var last *spawnZone
+	for i := range p.zones {
+		zone := &p.zones[i]
+		if !zone.allows(kind) {
+			continue
+		}
+		last = zone
+		roll -= zone.Weight
+		if roll < 0 {
+			return zone
+		}
+	}
+	return last
+}
+
+func (g *Game) spawnPointFree(x, y, radius float64) bool {
+	for _, player := range g.Players {
+		if player.Alive && math.Hypot(player.X-x, player.Y-y) < g.population.playerDistance+radius {
+			return false
+		}
+	}
+	for _, obj := range g.Objects {
+		if obj.Active && math.Hypot(obj.X-x, obj.Y-y) < obj.Radius+radius+g.population.objectDistance {
+			return false
+		}
+	}
+	return true
+}
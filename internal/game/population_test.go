@@ -0,0 +1,54 @@
+package game
+
+import (
+	"testing"
+)
+
+// populate заполняет карту комнаты с семенем seed до maxObjects объектов и
+// возвращает число объектов каждого вида
+func populate(seed int64, maxObjects int) map[string]int {
+	g := NewGame(WithSeed(seed))
+	g.CleanupObjects()
+	g.InitObjectSystem(maxObjects, g.RespawnDelay)
+
+	counts := make(map[string]int)
+	for _, obj := range g.Objects {
+		counts[obj.Type]++
+	}
+	return counts
+}
+
+// Вес вида определяет его долю на карте, а предел MaxCount не превышается
+func TestPopulationFollowsWeights(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxObjects int
+	}{
+		{"по умолчанию", 30},
+		{"тесная карта", 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const seeds = 200
+			total := make(map[string]int)
+			for seed := int64(1); seed <= seeds; seed++ {
+				counts := populate(seed, tt.maxObjects)
+				for _, kind := range DefaultObjectKinds {
+					if counts[kind.Name] > kind.MaxCount {
+						t.Fatalf("семя %d: %s на карте %d, предел %d", seed, kind.Name, counts[kind.Name], kind.MaxCount)
+					}
+					total[kind.Name] += counts[kind.Name]
+				}
+			}
+
+			if !(total["square"] > total["triangle"] && total["triangle"] > total["pentagon"] && total["pentagon"] > 0) {
+				t.Fatalf("доли видов не следуют весам: %v", total)
+			}
+			// Редкий вид с весом 0.5 появляется лишь на малой части карт
+			if alpha := total["alpha_pentagon"]; alpha == 0 || alpha > seeds/4 {
+				t.Fatalf("alpha_pentagon появился на %d картах из %d", alpha, seeds)
+			}
+		})
+	}
+}
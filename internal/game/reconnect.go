@@ -0,0 +1,160 @@
+package game
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"time"
+)
+
+const DefaultReconnectGrace = 30 * time.Second // Сколько ждать возвращения отключившегося игрока
+
+// entitySession - владелец ожидания переподключения игрока. Отдельный вид
+// нужен, чтобы возвращение игрока не отменяло его респавн и неуязвимость
+const entitySession entityKind = 254
+
+func sessionOwner(id uint) entityRef {
+	return entityRef{kind: entitySession, id: id}
+}
+
+// WithReconnectGrace задаёт, сколько отключившийся игрок остаётся в комнате.
+// Отрицательное значение удаляет игрока сразу после разрыва соединения
+func WithReconnectGrace(d time.Duration) Option {
+	return func(g *Game) {
+		if d != 0 {
+			g.ReconnectGrace = d
+		}
+	}
+}
+
+// WithPlayerExpired задаёт обработчик удаления игрока, не вернувшегося за
+// ReconnectGrace. Вызывается из шага симуляции под g.Mutex
+func WithPlayerExpired(fn func(*Game)) Option {
+	return func(g *Game) {
+		g.onPlayerExpired = fn
+	}
+}
+
+// DisconnectPlayer вызывается, когда соединение conn игрока закрылось.
+// Игрок замирает на месте и ждёт переподключения ReconnectGrace.
+// Возвращает true, если игрока больше нет в комнате
+func (g *Game) DisconnectPlayer(id uint, conn Connection) bool {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	player, exists := g.Players[id]
+	if !exists {
+		return true
+	}
+	// Игрок мог уже вернуться на новом соединении
+	if player.Conn != conn {
+		return false
+	}
+	if g.ReconnectGrace <= 0 {
+		g.removePlayer(id)
+		return true
+	}
+
+	g.record(replayEvent{Kind: replayDisconnect, PlayerID: id})
+	g.disconnectPlayer(player)
+	log.Printf("Игрок %d отключился, ожидаем переподключения %v", id, g.ReconnectGrace)
+	return false
+}
+
+// disconnectPlayer замораживает игрока и планирует его удаление.
+// Вызывается под g.Mutex
+func (g *Game) disconnectPlayer(player *Player) {
+	player.Conn = nil
+	player.disconnected = true
+	player.input = PlayerInputData{}
+	player.shootQueued = false
+
+	g.after(g.ReconnectGrace, sessionOwner(player.ID), func() {
+		log.Printf("Игрок %d не вернулся за %v", player.ID, g.ReconnectGrace)
+		g.removePlayer(player.ID)
+		if g.onPlayerExpired != nil {
+			g.onPlayerExpired(g)
+		}
+	})
+}
+
+// ResumePlayer возвращает игрока на новое соединение conn с сохранением
+// уровня, опыта и статов. Отключившегося игрока возвращаем по ID, а
+// соединение, которое сервер ещё считает живым, перехватываем только
+// с токеном возобновления
+func (g *Game) ResumePlayer(id uint, token string, conn Connection) error {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	player, exists := g.Players[id]
+	if !exists {
+		return ErrPlayerNotFound
+	}
+	if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(player.resumeToken)) != 1 {
+		return ErrInvalidResumeToken
+	}
+	if !player.disconnected {
+		if token == "" {
+			return ErrPlayerExists
+		}
+		// Старое соединение полуоткрыто: закрываем его, читатель выйдет сам
+		if player.Conn != nil {
+			player.Conn.Close()
+		}
+	}
+
+	g.record(replayEvent{Kind: replayResume, PlayerID: id})
+	g.resumePlayer(player)
+	g.inbox.forget(id)
+	g.anticheat.restart(id)
+	player.Conn = conn
+	// У нового клиента нет подтверждённых снимков
+	player.view = newClientView()
+	g.issueResumeToken(player)
+	log.Printf("Игрок %d переподключился", id)
+	return nil
+}
+
+// resumePlayer отменяет ожидание переподключения. Новый клиент нумерует
+// ввод заново, поэтому счётчик принятого ввода сбрасывается
+func (g *Game) resumePlayer(player *Player) {
+	g.scheduler.cancelOwner(sessionOwner(player.ID))
+	player.disconnected = false
+	player.lastSeq = 0
+	player.lastCommandSeq = 0
+	player.input = PlayerInputData{}
+	player.shootQueued = false
+}
+
+// HasPlayer сообщает, есть ли в комнате игрок id, в том числе отключившийся
+func (g *Game) HasPlayer(id uint) bool {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+
+	_, exists := g.Players[id]
+	return exists
+}
+
+// ResumeToken возвращает токен, с которым игрок может перехватить сессию
+func (g *Game) ResumeToken(id uint) string {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+
+	if player, exists := g.Players[id]; exists {
+		return player.resumeToken
+	}
+	return ""
+}
+
+// issueResumeToken выдаёт игроку новый токен при каждом подключении.
+// Токен не влияет на симуляцию, поэтому берётся из crypto/rand
+func (g *Game) issueResumeToken(player *Player) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Ошибка генерации токена возобновления игрока %d: %v", player.ID, err)
+		player.resumeToken = ""
+		return
+	}
+	player.resumeToken = hex.EncodeToString(buf)
+}
@@ -0,0 +1,114 @@
+package game
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gameCore/internal/protocol"
+)
+
+// recordingConn запоминает, закрыл ли его сервер
+type recordingConn struct {
+	closed bool
+}
+
+func (c *recordingConn) Send(msgType protocol.MessageType, payload interface{}) error { return nil }
+
+func (c *recordingConn) Close() { c.closed = true }
+
+func newReconnectGame(t *testing.T, opts ...Option) (*Game, *recordingConn) {
+	t.Helper()
+	g := NewGame(append([]Option{WithSeed(1), WithReconnectGrace(time.Second)}, opts...)...)
+	conn := &recordingConn{}
+	if err := g.AddPlayer(1, conn); err != nil {
+		t.Fatal(err)
+	}
+	g.Players[1].XP = 250
+	return g, conn
+}
+
+func TestResumePlayer(t *testing.T) {
+	tests := []struct {
+		name         string
+		disconnected bool
+		token        func(g *Game) string
+		err          error
+	}{
+		{"отключившийся игрок без токена", true, func(*Game) string { return "" }, nil},
+		{"отключившийся игрок с токеном", true, func(g *Game) string { return g.ResumeToken(1) }, nil},
+		{"неверный токен", true, func(*Game) string { return "bad" }, ErrInvalidResumeToken},
+		{"живое соединение без токена", false, func(*Game) string { return "" }, ErrPlayerExists},
+		{"перехват живого соединения", false, func(g *Game) string { return g.ResumeToken(1) }, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, old := newReconnectGame(t)
+			if tt.disconnected {
+				g.DisconnectPlayer(1, old)
+			}
+			token := tt.token(g)
+
+			conn := &recordingConn{}
+			err := g.ResumePlayer(1, token, conn)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка %v, ожидалась %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			player := g.Players[1]
+			if player.Conn != conn || player.disconnected || player.XP != 250 {
+				t.Fatal("игрок вернулся без прежнего прогресса или на старом соединении")
+			}
+			if !tt.disconnected && !old.closed {
+				t.Fatal("перехваченное соединение не закрыто")
+			}
+			if g.ResumeToken(1) == token && token != "" {
+				t.Fatal("после возвращения токен не сменился")
+			}
+		})
+	}
+}
+
+// Игрок, не вернувшийся за ReconnectGrace, удаляется из комнаты
+func TestReconnectGraceExpires(t *testing.T) {
+	expired := 0
+	g, conn := newReconnectGame(t, WithPlayerExpired(func(*Game) { expired++ }))
+	g.DisconnectPlayer(1, conn)
+
+	grace := g.durationToTicks(time.Second)
+	for i := uint64(1); i < grace; i++ {
+		g.step()
+	}
+	if !g.HasPlayer(1) {
+		t.Fatal("игрок удалён раньше окончания ожидания")
+	}
+	g.step()
+
+	if g.HasPlayer(1) || expired != 1 {
+		t.Fatalf("игрок в комнате: %v, обработчик вызван %d раз", g.HasPlayer(1), expired)
+	}
+	if err := g.ResumePlayer(1, "", &recordingConn{}); !errors.Is(err, ErrPlayerNotFound) {
+		t.Fatalf("ожидалась ErrPlayerNotFound, получено %v", err)
+	}
+}
+
+// Возвращение отменяет удаление по истечении ожидания
+func TestResumeCancelsGraceExpiry(t *testing.T) {
+	g, conn := newReconnectGame(t)
+	g.DisconnectPlayer(1, conn)
+	g.step()
+	if err := g.ResumePlayer(1, "", &recordingConn{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := uint64(0); i <= g.durationToTicks(time.Second); i++ {
+		g.step()
+	}
+	if !g.HasPlayer(1) {
+		t.Fatal("вернувшийся игрок удалён по старому таймеру")
+	}
+}
@@ -0,0 +1,409 @@
+package game
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gameCore/internal/config"
+	"gameCore/internal/protocol"
+)
+
+const (
+	ReplayVersion    = 2  // 2 - команды записываются отдельно от ввода движения
+	ChecksumInterval = 60 // Шагов между контрольными суммами состояния в записи
+	replayBatches    = 16 // Ёмкость очереди пачек событий к пишущей горутине
+)
+
+// replayHeader открывает файл записи: всё, что нужно для повторной симуляции.
+// Дополнительные Option, переданные в NewRoomManager, в запись не попадают
+type replayHeader struct {
+	Version    int
+	RoomID     string
+	SessionID  uint
+	Seed       int64
+	TickRate   time.Duration
+	MaxPlayers int
+	Config     config.GameConfig
+	StartedAt  time.Time
+}
+
+type replayEventKind uint8
+
+const (
+	replayJoin replayEventKind = iota + 1
+	replayLeave
+	replayInput
+	replayChecksum
+	replayEnd        // Матч остановлен после шага Tick
+	replayDisconnect // Соединение игрока разорвано, игрок ждёт переподключения
+	replayResume     // Игрок вернулся на новом соединении
+	replayCommand    // Разовая команда: улучшение, выбор класса или возрождение
+)
+
+// replayEvent - событие записи. Tick - номер шага, после которого событие
+// произошло: ввод и подключения попадают в симуляцию на шаге Tick+1
+type replayEvent struct {
+	Tick     uint64
+	Kind     replayEventKind
+	PlayerID uint
+	Input    PlayerInputData
+	Shot     bool // Выстрел из объединённого ввода
+	Checksum uint64
+}
+
+// WithReplay включает запись матча в каталог dir. cfg сохраняется в заголовок,
+// чтобы запись можно было пересимулировать с теми же настройками
+func WithReplay(dir string, cfg config.GameConfig) Option {
+	return func(g *Game) {
+		g.replayDir = dir
+		g.replayConfig = cfg
+	}
+}
+
+// ReplayPath возвращает путь к файлу записи игровой сессии
+func ReplayPath(dir string, sessionID uint) string {
+	return filepath.Join(dir, fmt.Sprintf("session-%d.replay", sessionID))
+}
+
+func (g *Game) replayPath() string {
+	if g.SessionID != 0 {
+		return ReplayPath(g.replayDir, g.SessionID)
+	}
+	return filepath.Join(g.replayDir, fmt.Sprintf("room-%s.replay", g.ID))
+}
+
+// replayRecorder копит события шага и отдаёт их пачками пишущей горутине,
+// чтобы сжатие и запись на диск не шли под g.Mutex
+type replayRecorder struct {
+	path    string
+	pending []replayEvent
+	batches chan []replayEvent
+	done    chan struct{}
+	failed  bool // Диск не успевал, дальше матч не записывается
+}
+
+func newReplayRecorder(path string, header replayHeader) (*replayRecorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create replay dir: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create replay file: %w", err)
+	}
+
+	zw := gzip.NewWriter(file)
+	enc := gob.NewEncoder(zw)
+	if err := enc.Encode(header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("write replay header: %w", err)
+	}
+
+	r := &replayRecorder{
+		path:    path,
+		batches: make(chan []replayEvent, replayBatches),
+		done:    make(chan struct{}),
+	}
+	go r.writeLoop(file, zw, enc)
+	return r, nil
+}
+
+func (r *replayRecorder) writeLoop(file *os.File, zw *gzip.Writer, enc *gob.Encoder) {
+	defer close(r.done)
+	defer file.Close()
+
+	failed := false
+	for batch := range r.batches {
+		if failed {
+			continue
+		}
+		for _, event := range batch {
+			if err := enc.Encode(event); err != nil {
+				log.Printf("Ошибка записи повтора %s: %v", file.Name(), err)
+				failed = true
+				break
+			}
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("Ошибка закрытия повтора %s: %v", file.Name(), err)
+	}
+}
+
+func (r *replayRecorder) add(event replayEvent) {
+	if r.failed {
+		return
+	}
+	r.pending = append(r.pending, event)
+}
+
+// flush отдаёт пачку пишущей горутине без ожидания: он вызывается под
+// g.Mutex, и зависший диск не должен останавливать симуляцию. Если очередь
+// заполнена, запись прекращается, а в файле остаётся целое начало матча
+func (r *replayRecorder) flush() {
+	if r.failed || len(r.pending) == 0 {
+		return
+	}
+	select {
+	case r.batches <- r.pending:
+	default:
+		r.failed = true
+		log.Printf("Запись повтора %s прервана: диск не успевает за матчем", r.path)
+	}
+	r.pending = nil
+}
+
+// finish отдаёт оставшиеся события и закрывает очередь. Вызывается под
+// g.Mutex; закрытия файла ждёт wait уже без блокировки
+func (r *replayRecorder) finish() {
+	r.flush()
+	close(r.batches)
+}
+
+// wait ждёт, пока пишущая горутина закроет файл, но не дольше ctx
+func (r *replayRecorder) wait(ctx context.Context) error {
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("запись повтора %s не закрыта: %w", r.path, ctx.Err())
+	}
+}
+
+// startRecording вызывается из Start под g.Mutex
+func (g *Game) startRecording() {
+	if g.replayDir == "" {
+		return
+	}
+
+	recorder, err := newReplayRecorder(g.replayPath(), replayHeader{
+		Version:    ReplayVersion,
+		RoomID:     g.ID,
+		SessionID:  g.SessionID,
+		Seed:       g.Seed,
+		TickRate:   g.TickRate,
+		MaxPlayers: g.MaxPlayers,
+		Config:     g.replayConfig,
+		StartedAt:  time.Now(),
+	})
+	if err != nil {
+		log.Printf("Запись матча в комнате %s отключена: %v", g.ID, err)
+		return
+	}
+	g.recorder = recorder
+}
+
+// stopRecording вызывается из Stop под g.Mutex и отцепляет запись от
+// комнаты. Закрытия файла вызывающий ждёт через wait после разблокировки
+func (g *Game) stopRecording() *replayRecorder {
+	recorder := g.recorder
+	if recorder == nil {
+		return nil
+	}
+	g.record(replayEvent{Kind: replayEnd})
+	recorder.finish()
+	g.recorder = nil
+	return recorder
+}
+
+func (g *Game) record(event replayEvent) {
+	if g.recorder == nil {
+		return
+	}
+	event.Tick = g.Tick
+	g.recorder.add(event)
+}
+
+// recordChecksum вызывается в конце шага и заодно отдаёт накопленную пачку на запись
+func (g *Game) recordChecksum() {
+	if g.recorder == nil || g.Tick%ChecksumInterval != 0 {
+		return
+	}
+	g.record(replayEvent{Kind: replayChecksum, Checksum: g.checksum()})
+	g.recorder.flush()
+}
+
+// checksum - хеш сериализованного мира. encoding/json сортирует ключи
+// карт, поэтому одинаковый мир даёт одинаковый хеш
+func (g *Game) checksum() uint64 {
+	// Задержка зависит от сети, а не от симуляции, и в повторе её нет
+	state := g.serializeState()
+	for id, p := range state.Players {
+		p.RTT = 0
+		state.Players[id] = p
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write(data)
+	return h.Sum64()
+}
+
+// Replay - загруженная запись матча
+type Replay struct {
+	header replayHeader
+	events []replayEvent
+}
+
+// LoadReplay читает файл записи целиком
+func LoadReplay(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrReplayNotFound
+		}
+		return nil, fmt.Errorf("open replay: %w", err)
+	}
+	defer file.Close()
+
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("read replay: %w", err)
+	}
+	dec := gob.NewDecoder(zr)
+
+	replay := &Replay{}
+	if err := dec.Decode(&replay.header); err != nil {
+		return nil, fmt.Errorf("read replay header: %w", err)
+	}
+	if replay.header.Version != ReplayVersion {
+		return nil, fmt.Errorf("%w: версия %d", ErrReplayVersion, replay.header.Version)
+	}
+
+	for {
+		var event replayEvent
+		err := dec.Decode(&event)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// Оборванная запись (например, после падения) проигрывается до обрыва
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read replay event: %w", err)
+		}
+		replay.events = append(replay.events, event)
+	}
+	return replay, nil
+}
+
+// RoomID возвращает комнату, в которой шёл записанный матч
+func (r *Replay) RoomID() string {
+	return r.header.RoomID
+}
+
+// Simulate заново проигрывает матч без сети. onStep вызывается после
+// каждого шага и может остановить проигрывание, вернув false. Расхождение
+// контрольной суммы возвращает ErrReplayDesync
+func (r *Replay) Simulate(onStep func(g *Game) bool) error {
+	opts := append(gameOptions(r.header.Config),
+		WithID(r.header.RoomID),
+		WithSeed(r.header.Seed),
+		WithTickRate(r.header.TickRate),
+		WithMaxPlayers(r.header.MaxPlayers),
+	)
+	g := NewGame(opts...)
+	g.SessionID = r.header.SessionID
+	g.scheduleMatchEnd()
+
+	next := 0
+	for next < len(r.events) {
+		for next < len(r.events) && r.events[next].Tick <= g.Tick {
+			if r.events[next].Kind == replayEnd {
+				return nil
+			}
+			if err := g.applyReplayEvent(r.events[next]); err != nil {
+				return err
+			}
+			next++
+		}
+		if next >= len(r.events) {
+			return nil
+		}
+
+		g.step()
+		if onStep != nil && !onStep(g) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (g *Game) applyReplayEvent(event replayEvent) error {
+	switch event.Kind {
+	case replayJoin:
+		return g.AddPlayer(event.PlayerID, nil)
+	case replayLeave:
+		g.RemovePlayer(event.PlayerID)
+	case replayDisconnect, replayResume:
+		g.Mutex.Lock()
+		if player, exists := g.Players[event.PlayerID]; exists {
+			if event.Kind == replayDisconnect {
+				g.disconnectPlayer(player)
+			} else {
+				g.resumePlayer(player)
+			}
+		}
+		g.Mutex.Unlock()
+	case replayInput:
+		g.collectInput(PlayerInput{ID: event.PlayerID, Input: event.Input, Shot: event.Shot})
+	case replayCommand:
+		g.collectCommand(PlayerInput{ID: event.PlayerID, Input: event.Input})
+	case replayChecksum:
+		if sum := g.checksum(); sum != event.Checksum {
+			return fmt.Errorf("%w: тик %d", ErrReplayDesync, event.Tick)
+		}
+	}
+	return nil
+}
+
+// Stream проигрывает запись клиенту в темпе живого матча, ускоренном в
+// speed раз. acks - подтверждения снимков от клиента для дельта-сжатия
+func (r *Replay) Stream(ctx context.Context, conn Connection, acks <-chan uint32, speed float64) error {
+	if speed <= 0 {
+		speed = 1
+	}
+	interval := time.Duration(float64(r.header.TickRate) / speed)
+	if interval <= 0 {
+		return fmt.Errorf("%w: скорость %g", ErrReplaySpeed, speed)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	view := newClientView()
+	var sendErr error
+	err := r.Simulate(func(g *Game) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+
+		for drained := false; !drained; {
+			select {
+			case ack := <-acks:
+				view.ack(ack)
+			default:
+				drained = true
+			}
+		}
+
+		if sendErr = conn.Send(protocol.TypeState, view.build(g.serializeState(), g.Tick)); sendErr != nil {
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return sendErr
+}
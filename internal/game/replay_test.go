@@ -0,0 +1,146 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"gameCore/internal/config"
+)
+
+// recordMatch проводит короткий матч без сети и игрового цикла и
+// возвращает путь к его записи
+func recordMatch(t *testing.T, ticks int) string {
+	t.Helper()
+	dir := t.TempDir()
+	// Ввод теста идёт с машинной точностью, поэтому античит не отключает игроков
+	cfg := config.GameConfig{AntiCheat: config.AntiCheatConfig{KickScore: -1}}
+
+	g := NewGame(append(gameOptions(cfg), WithID("replay-test"), WithSeed(42), WithReplay(dir, cfg))...)
+	g.Mutex.Lock()
+	g.Running = true
+	g.startRecording()
+	g.Mutex.Unlock()
+	if g.recorder == nil {
+		t.Fatal("запись матча не началась")
+	}
+
+	for _, id := range []uint{1, 2, 3} {
+		if err := g.AddPlayer(id, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for tick := 0; tick < ticks; tick++ {
+		for _, id := range []uint{1, 2, 3} {
+			phase := tick + int(id)*17
+			input := PlayerInputData{
+				Up:    phase%40 < 20,
+				Down:  phase%40 >= 20,
+				Left:  phase%90 < 30,
+				Right: phase%90 >= 60,
+				Angle: float64(phase%63) / 10,
+				Shoot: phase%5 == 0,
+				Seq:   uint32(tick + 1),
+			}
+			if tick%50 == int(id) {
+				input.UpgradeStat = "damage"
+			}
+			if err := g.SubmitInput(PlayerInput{ID: id, Input: input}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tick == ticks/2 {
+			g.DisconnectPlayer(3, nil)
+		}
+		g.drainInbox()
+		g.step()
+	}
+
+	path := g.replayPath()
+	g.Stop()
+	return path
+}
+
+// Пересимуляция записанного матча должна совпасть со всеми контрольными суммами
+func TestReplaySimulatesRecordedMatch(t *testing.T) {
+	replay, err := LoadReplay(recordMatch(t, 10*ChecksumInterval))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checksums := 0
+	for _, event := range replay.events {
+		if event.Kind == replayChecksum {
+			checksums++
+		}
+	}
+	if checksums < 10 {
+		t.Fatalf("в записи %d контрольных сумм, ожидалось не меньше 10", checksums)
+	}
+
+	steps := 0
+	err = replay.Simulate(func(g *Game) bool {
+		steps++
+		return true
+	})
+	if errors.Is(err, ErrReplayDesync) {
+		t.Fatalf("повтор разошёлся с записью: %v", err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if steps < 10*ChecksumInterval {
+		t.Fatalf("проиграно %d шагов из %d", steps, 10*ChecksumInterval)
+	}
+}
+
+// Заполненная очередь записи не должна останавливать шаг симуляции
+func TestReplayFlushDoesNotBlock(t *testing.T) {
+	r := &replayRecorder{path: "stalled.replay", batches: make(chan []replayEvent, 1)}
+	r.batches <- nil
+
+	r.add(replayEvent{Kind: replayChecksum})
+	r.flush()
+	if !r.failed {
+		t.Fatal("запись должна прекратиться, когда диск не успевает")
+	}
+
+	r.add(replayEvent{Kind: replayChecksum})
+	if len(r.pending) != 0 {
+		t.Fatal("прерванная запись не должна копить события")
+	}
+}
+
+// Остановка комнаты ждёт зависший диск не дольше контекста и не держит g.Mutex
+func TestStopDoesNotWaitForStalledDisk(t *testing.T) {
+	g := NewGame(WithSeed(1))
+	g.Running = true
+	g.recorder = &replayRecorder{
+		path:    "stalled.replay",
+		batches: make(chan []replayEvent, replayBatches),
+		done:    make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := g.StopContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ожидалась ошибка по истечении контекста, получено %v", err)
+	}
+	if !g.Mutex.TryLock() {
+		t.Fatal("g.Mutex не отпущен после остановки")
+	}
+	g.Mutex.Unlock()
+}
+
+// Скорость, при которой интервал тика обнуляется, отклоняется без паники
+func TestReplayStreamRejectsZeroInterval(t *testing.T) {
+	replay := &Replay{header: replayHeader{TickRate: time.Second / 60}}
+	for _, speed := range []float64{math.Inf(1), 1e18} {
+		if err := replay.Stream(context.Background(), nil, nil, speed); !errors.Is(err, ErrReplaySpeed) {
+			t.Fatalf("скорость %g: ожидалась ErrReplaySpeed, получено %v", speed, err)
+		}
+	}
+}
@@ -0,0 +1,653 @@
+package game
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gameCore/internal/config"
+	"gameCore/internal/repository"
+	"gameCore/pkg/models"
+)
+
+// room - игровая комната под управлением RoomManager
+type room struct {
+	game      *Game
+	owner     uint // Кто создал комнату через API, 0 - матчмейкинг
+	autoClose bool // Комната создана матчмейкингом и закрывается, когда опустеет
+}
+
+// RoomInfo - краткое описание комнаты для API
+type RoomInfo struct {
+	ID         string `json:"id"`
+	SessionID  uint   `json:"session_id"`
+	Players    int    `json:"players"`
+	MaxPlayers int    `json:"max_players"`
+	Spectators int    `json:"spectators"`
+}
+
+// RoomManager владеет множеством независимых игровых комнат
+type RoomManager struct {
+	mu         sync.Mutex
+	rooms      map[string]*room
+	reserved   map[string]struct{} // ID комнат, для которых создаётся игровая сессия
+	kicked     map[uint]time.Time  // До какого времени игрок, отключённый античитом, не допускается
+	cooldown   time.Duration
+	sessions   repository.GameSessionRepository
+	players    repository.PlayerRepository
+	flags      repository.CheatFlagRepository
+	maxPlayers int
+	closed     bool // Менеджер остановлен, новые комнаты и игроки не принимаются
+	cfg        config.GameConfig
+	opts       []Option
+	handlers   []EventHandler // Подписчики событий всех комнат
+}
+
+func NewRoomManager(sessions repository.GameSessionRepository, players repository.PlayerRepository, flags repository.CheatFlagRepository, cfg config.GameConfig, opts ...Option) *RoomManager {
+	maxPlayers := cfg.MaxPlayers
+	if maxPlayers <= 0 {
+		maxPlayers = 20
+	}
+
+	cooldown := cfg.AntiCheat.KickCooldown
+	if cooldown == 0 {
+		cooldown = DefaultKickCooldown
+	}
+
+	return &RoomManager{
+		rooms:      make(map[string]*room),
+		reserved:   make(map[string]struct{}),
+		kicked:     make(map[uint]time.Time),
+		cooldown:   cooldown,
+		sessions:   sessions,
+		players:    players,
+		flags:      flags,
+		maxPlayers: maxPlayers,
+		cfg:        cfg,
+		opts:       opts,
+	}
+}
+
+// SubscribeEvents подписывает fn на события всех комнат, созданных после
+// подписки. Подписываться нужно до приёма игроков
+func (m *RoomManager) SubscribeEvents(fn EventHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlers = append(m.handlers, fn)
+}
+
+// CreateRoom создаёт и запускает новую комнату игрока owner. Пустой id
+// генерируется автоматически. Вызывается без m.mu: запись игровой сессии
+// в базу не должна задерживать вход и выход игроков в других комнатах.
+// Пока сессия создаётся, ID комнаты зарезервирован
+func (m *RoomManager) CreateRoom(ctx context.Context, id string, owner uint) (*Game, error) {
+	id, err := m.reserve(id)
+	if err != nil {
+		return nil, err
+	}
+	defer m.release(id)
+
+	g, err := m.newRoomGame(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrShuttingDown
+	}
+	m.rooms[id] = &room{game: g, owner: owner}
+	g.Start()
+
+	log.Printf("Создана комната %s (сессия %d)", id, g.SessionID)
+	return g, nil
+}
+
+// reserve занимает ID комнаты, генерируя его при необходимости
+func (m *RoomManager) reserve(id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return "", ErrShuttingDown
+	}
+	if id == "" {
+		generated, err := newRoomID()
+		if err != nil {
+			return "", err
+		}
+		id = generated
+	}
+	_, exists := m.rooms[id]
+	_, pending := m.reserved[id]
+	if exists || pending {
+		return "", ErrRoomExists
+	}
+	m.reserved[id] = struct{}{}
+	return id, nil
+}
+
+// release снимает резерв с ID комнаты
+func (m *RoomManager) release(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.reserved, id)
+}
+
+// newRoomGame собирает комнату и записывает её игровую сессию
+func (m *RoomManager) newRoomGame(ctx context.Context, id string) (*Game, error) {
+	seed, err := newSeed()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := append(gameOptions(m.cfg),
+		WithID(id),
+		WithSeed(seed),
+		WithMaxPlayers(m.maxPlayers),
+		WithMatchTime(m.cfg.MatchTime, func(g *Game) {
+			// Вызывается из шага симуляции под g.Mutex, поэтому закрываем асинхронно
+			go m.finishMatch(g)
+		}),
+		WithReplay(m.cfg.ReplayDir, m.cfg),
+		WithPlayerExpired(func(g *Game) {
+			go m.closeIfEmpty(context.Background(), g)
+		}),
+		WithCheatHandler(func(g *Game, report CheatReport) {
+			// Запрет на возврат ставим до ответа соединению, чтобы
+			// переподключение не успело его обойти
+			if report.Kicked {
+				m.kick(report.PlayerID)
+			}
+			go m.flagPlayer(g.SessionID, report)
+		}),
+	)
+	for _, handler := range m.handlers {
+		opts = append(opts, WithEventHandler(handler))
+	}
+	g := NewGame(append(opts, m.opts...)...)
+
+	if m.sessions != nil {
+		// ID закрытой комнаты занят её сессией и повторно не выдаётся
+		taken, err := m.sessions.GameSessionExists(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("check game session: %w", err)
+		}
+		if taken {
+			return nil, ErrRoomExists
+		}
+
+		session := &models.GameSession{
+			GameID:     id,
+			StartTime:  time.Now(),
+			MaxPlayers: m.maxPlayers,
+			Seed:       g.Seed,
+		}
+		if err := m.sessions.CreateGameSession(ctx, session); err != nil {
+			return nil, fmt.Errorf("create game session: %w", err)
+		}
+		g.SessionID = session.ID
+	}
+	return g, nil
+}
+
+// gameOptions - настройки симуляции из конфигурации. Общие для живых
+// комнат и повторов, чтобы повтор шёл по тем же правилам
+func gameOptions(cfg config.GameConfig) []Option {
+	return []Option{
+		WithTickRate(cfg.TickRate),
+		WithInterest(cfg.ViewRadius, cfg.InterestCellSize),
+		WithMaxRewind(cfg.MaxRewind),
+		WithClasses(cfg.Classes),
+		WithUpgrades(cfg.Upgrades),
+		WithObjectKinds(cfg.ObjectKinds),
+		WithSpawnZones(cfg.SpawnZones, cfg.SpawnPlayerDistance, cfg.SpawnObjectDistance),
+		WithMatchTime(cfg.MatchTime, nil),
+		WithReconnectGrace(cfg.ReconnectGrace),
+		WithAntiCheat(cfg.AntiCheat),
+	}
+}
+
+// OpenReplay загружает запись игровой сессии
+func (m *RoomManager) OpenReplay(sessionID uint) (*Replay, error) {
+	if m.cfg.ReplayDir == "" {
+		return nil, ErrReplayDisabled
+	}
+	return LoadReplay(ReplayPath(m.cfg.ReplayDir, sessionID))
+}
+
+// GetRoom возвращает комнату по идентификатору
+func (m *RoomManager) GetRoom(id string) (*Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, exists := m.rooms[id]
+	if !exists {
+		return nil, ErrRoomNotFound
+	}
+	return r.game, nil
+}
+
+// DestroyRoom останавливает комнату по просьбе игрока userID и закрывает
+// её игровую сессию. Удалить комнату может только её создатель
+func (m *RoomManager) DestroyRoom(ctx context.Context, id string, userID uint) error {
+	m.mu.Lock()
+	r, exists := m.rooms[id]
+	if !exists {
+		m.mu.Unlock()
+		return ErrRoomNotFound
+	}
+	if r.owner == 0 || r.owner != userID {
+		m.mu.Unlock()
+		return ErrNotRoomOwner
+	}
+	delete(m.rooms, id)
+	m.mu.Unlock()
+
+	return m.closeRoom(ctx, r.game)
+}
+
+// Shutdown останавливает все комнаты и сохраняет итоги их матчей.
+// После него менеджер не создаёт комнат и не принимает игроков
+func (m *RoomManager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.closed = true
+	rooms := make([]*Game, 0, len(m.rooms))
+	for id, r := range m.rooms {
+		rooms = append(rooms, r.game)
+		delete(m.rooms, id)
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, g := range rooms {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("комнаты не закрыты: %w", err))
+			break
+		}
+		if err := m.closeRoom(ctx, g); err != nil {
+			errs = append(errs, fmt.Errorf("room %s: %w", g.ID, err))
+		}
+	}
+	log.Printf("Остановлено комнат: %d", len(rooms))
+	return errors.Join(errs...)
+}
+
+func (m *RoomManager) closeRoom(ctx context.Context, g *Game) error {
+	if err := g.StopContext(ctx); err != nil {
+		log.Printf("Ошибка остановки комнаты %s: %v", g.ID, err)
+	}
+
+	g.Mutex.Lock()
+	// Итоги снимаем до удаления игроков, включая ждущих переподключения
+	standings := g.standings()
+	for id, player := range g.Players {
+		if player.Conn != nil {
+			player.Conn.Close()
+		}
+		delete(g.Players, id)
+	}
+	g.playerOrder = nil
+	for id, spectator := range g.Spectators {
+		if spectator.Conn != nil {
+			spectator.Conn.Close()
+		}
+		delete(g.Spectators, id)
+	}
+	g.Mutex.Unlock()
+
+	log.Printf("Комната %s закрыта", g.ID)
+
+	if err := m.saveResults(ctx, g, standings); err != nil {
+		return err
+	}
+	if m.sessions == nil {
+		return nil
+	}
+
+	session, err := m.sessions.GetGameSession(ctx, g.ID)
+	if err != nil {
+		return fmt.Errorf("get game session: %w", err)
+	}
+	session.EndTime = time.Now()
+	if err := m.sessions.UpdateGameSession(ctx, session); err != nil {
+		return fmt.Errorf("update game session: %w", err)
+	}
+	return nil
+}
+
+// saveResults сохраняет уровень и опыт каждого игрока матча
+func (m *RoomManager) saveResults(ctx context.Context, g *Game, standings []standing) error {
+	if m.players == nil || g.SessionID == 0 {
+		return nil
+	}
+
+	results := make([]models.Player, 0, len(standings))
+	for _, s := range standings {
+		results = append(results, models.Player{
+			UserID:        s.ID,
+			GameSessionID: g.SessionID,
+			Score:         s.XP,
+			Level:         s.Level,
+		})
+	}
+	if err := m.players.SaveResults(ctx, results); err != nil {
+		return fmt.Errorf("save match results: %w", err)
+	}
+	return nil
+}
+
+// kick запрещает игроку, отключённому античитом, входить в комнаты на
+// время cooldown. Очки подозрения уходят вместе с игроком из комнаты,
+// поэтому без запрета он сразу вернулся бы с чистого листа
+func (m *RoomManager) kick(userID uint) {
+	if m.cooldown < 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.kicked[userID] = time.Now().Add(m.cooldown)
+}
+
+// kickedLocked сообщает, действует ли запрет на вход игрока. Вызывается под m.mu
+func (m *RoomManager) kickedLocked(userID uint) bool {
+	until, ok := m.kicked[userID]
+	if !ok {
+		return false
+	}
+	if time.Now().Before(until) {
+		return true
+	}
+	delete(m.kicked, userID)
+	return false
+}
+
+// flagPlayer отмечает аккаунт для ручной проверки модератором
+func (m *RoomManager) flagPlayer(sessionID uint, report CheatReport) {
+	if m.flags == nil {
+		return
+	}
+
+	err := m.flags.CreateCheatFlag(context.Background(), &models.CheatFlag{
+		UserID:        report.PlayerID,
+		GameSessionID: sessionID,
+		Score:         report.Score,
+		Reasons:       report.ReasonList(),
+		Kicked:        report.Kicked,
+	})
+	if err != nil {
+		log.Printf("Ошибка отметки игрока %d античитом: %v", report.PlayerID, err)
+	}
+}
+
+// finishMatch закрывает комнату, матч которой закончился по времени
+func (m *RoomManager) finishMatch(g *Game) {
+	m.mu.Lock()
+	r, exists := m.rooms[g.ID]
+	if !exists || r.game != g {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.rooms, g.ID)
+	m.mu.Unlock()
+
+	if err := m.closeRoom(context.Background(), g); err != nil {
+		log.Printf("Ошибка закрытия комнаты %s: %v", g.ID, err)
+	}
+}
+
+// Rooms возвращает список активных комнат
+func (m *RoomManager) Rooms() []RoomInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rooms := make([]RoomInfo, 0, len(m.rooms))
+	for id, r := range m.rooms {
+		rooms = append(rooms, RoomInfo{
+			ID:         id,
+			SessionID:  r.game.SessionID,
+			Players:    r.game.PlayerCount(),
+			MaxPlayers: r.game.MaxPlayers,
+			Spectators: r.game.SpectatorCount(),
+		})
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID < rooms[j].ID })
+	return rooms
+}
+
+// JoinRoom подключает игрока к комнате roomID, а при пустом roomID -
+// к комнате, подобранной матчмейкингом. Если игрок ещё числится в
+// какой-либо комнате, он возвращается туда с прежним прогрессом.
+// resumeToken нужен, только чтобы перехватить ещё живое соединение
+func (m *RoomManager) JoinRoom(ctx context.Context, roomID string, userID uint, conn Connection, resumeToken string) (*Game, error) {
+	g, err := m.joinExisting(roomID, userID, conn, resumeToken)
+	if g != nil || err != nil {
+		return g, err
+	}
+
+	// Свободных комнат нет: новую создаём без m.mu
+	return m.matchNewRoom(ctx, userID, conn)
+}
+
+// matchNewRoom создаёт комнату матчмейкинга для игрока userID. Пока
+// записывается сессия, другой вход мог открыть комнату со свободным
+// местом: тогда игрок идёт туда, а новая комната закрывается, так и не
+// начавшись. Так же закрывается комната, в которую игрок не смог войти
+func (m *RoomManager) matchNewRoom(ctx context.Context, userID uint, conn Connection) (*Game, error) {
+	id, err := m.reserve("")
+	if err != nil {
+		return nil, err
+	}
+	defer m.release(id)
+
+	g, err := m.newRoomGame(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	joined, err := m.joinNewRoom(g, userID, conn)
+	if joined != g {
+		if closeErr := m.closeRoom(ctx, g); closeErr != nil {
+			log.Printf("Ошибка закрытия комнаты %s: %v", g.ID, closeErr)
+		}
+	}
+	return joined, err
+}
+
+// joinNewRoom регистрирует комнату g и подключает к ней игрока, если
+// матчмейкинг всё ещё не нашёл свободного места. Возвращает комнату, в
+// которую вошёл игрок
+func (m *RoomManager) joinNewRoom(g *Game, userID uint, conn Connection) (*Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrShuttingDown
+	}
+	if m.spectatingLocked(userID) {
+		return nil, ErrPlayerSpectates
+	}
+	if existing := m.matchLocked(); existing != nil {
+		if err := existing.AddPlayer(userID, conn); err != nil {
+			return nil, err
+		}
+		return existing, nil
+	}
+
+	m.rooms[g.ID] = &room{game: g, autoClose: true}
+	g.Start()
+	if err := g.AddPlayer(userID, conn); err != nil {
+		delete(m.rooms, g.ID)
+		return nil, err
+	}
+	log.Printf("Создана комната %s (сессия %d)", g.ID, g.SessionID)
+	return g, nil
+}
+
+// joinExisting подключает игрока к уже запущенной комнате. Возвращает
+// nil без ошибки, если матчмейкингу нужна новая комната
+func (m *RoomManager) joinExisting(roomID string, userID uint, conn Connection, resumeToken string) (*Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, ErrShuttingDown
+	}
+	if m.kickedLocked(userID) {
+		return nil, ErrKickCooldown
+	}
+	if m.spectatingLocked(userID) {
+		return nil, ErrPlayerSpectates
+	}
+	for _, r := range m.rooms {
+		if r.game.HasPlayer(userID) {
+			if err := r.game.ResumePlayer(userID, resumeToken, conn); err != nil {
+				return nil, err
+			}
+			return r.game, nil
+		}
+	}
+
+	if roomID != "" {
+		r, exists := m.rooms[roomID]
+		if !exists {
+			return nil, ErrRoomNotFound
+		}
+		if err := r.game.AddPlayer(userID, conn); err != nil {
+			return nil, err
+		}
+		return r.game, nil
+	}
+
+	g := m.matchLocked()
+	if g == nil {
+		return nil, nil
+	}
+	if err := g.AddPlayer(userID, conn); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Spectate подключает зрителя к комнате roomID. follow - ID игрока,
+// за которым следит камера, 0 - весь мир. Зритель видит мир без фильтра
+// видимости, поэтому участнику матча, в том числе ждущему
+// переподключения, смотреть любую комнату нельзя
+func (m *RoomManager) Spectate(roomID string, userID uint, conn Connection, follow uint) (*Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, exists := m.rooms[roomID]
+	if !exists {
+		return nil, ErrRoomNotFound
+	}
+	if m.playingLocked(userID) {
+		return nil, ErrSpectatorInGame
+	}
+	if err := r.game.AddSpectator(userID, conn, follow); err != nil {
+		return nil, err
+	}
+	return r.game, nil
+}
+
+// playingLocked сообщает, участвует ли пользователь в матче какой-либо
+// комнаты. Вызывается под m.mu
+func (m *RoomManager) playingLocked(userID uint) bool {
+	for _, r := range m.rooms {
+		if r.game.HasPlayer(userID) {
+			return true
+		}
+	}
+	return false
+}
+
+// spectatingLocked сообщает, смотрит ли пользователь какую-либо комнату.
+// Вызывается под m.mu
+func (m *RoomManager) spectatingLocked(userID uint) bool {
+	for _, r := range m.rooms {
+		if r.game.HasSpectator(userID) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchLocked выбирает самую заполненную комнату со свободными местами,
+// чтобы игроки не размазывались по пустым комнатам. nil - свободных нет
+func (m *RoomManager) matchLocked() *Game {
+	var best *Game
+	bestCount := -1
+
+	for _, r := range m.rooms {
+		count := r.game.PlayerCount()
+		if r.game.MaxPlayers > 0 && count >= r.game.MaxPlayers {
+			continue
+		}
+		if count > bestCount {
+			best, bestCount = r.game, count
+		}
+	}
+
+	return best
+}
+
+// LeaveRoom вызывается при разрыве соединения conn игрока. Игрок ждёт
+// переподключения, а комната матчмейкинга закрывается, когда опустеет
+func (m *RoomManager) LeaveRoom(ctx context.Context, g *Game, userID uint, conn Connection) {
+	if g.DisconnectPlayer(userID, conn) {
+		m.closeIfEmpty(ctx, g)
+	}
+}
+
+// KickPlayer отключает нарушителя без ожидания переподключения: игрок
+// теряет место в комнате и не может вернуться в течение cooldown
+func (m *RoomManager) KickPlayer(ctx context.Context, g *Game, userID uint) {
+	m.kick(userID)
+	g.RemovePlayer(userID)
+	m.closeIfEmpty(ctx, g)
+}
+
+// closeIfEmpty закрывает опустевшую комнату матчмейкинга
+func (m *RoomManager) closeIfEmpty(ctx context.Context, g *Game) {
+	m.mu.Lock()
+	r, exists := m.rooms[g.ID]
+	if !exists || r.game != g || !r.autoClose || g.PlayerCount() > 0 {
+		m.mu.Unlock()
+		return
+	}
+	delete(m.rooms, g.ID)
+	m.mu.Unlock()
+
+	if err := m.closeRoom(ctx, g); err != nil {
+		log.Printf("Ошибка закрытия комнаты %s: %v", g.ID, err)
+	}
+}
+
+func newRoomID() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate room id: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func newSeed() (int64, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return 0, fmt.Errorf("generate seed: %w", err)
+	}
+	return int64(binary.LittleEndian.Uint64(buf)), nil
+}
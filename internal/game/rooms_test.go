@@ -0,0 +1,191 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"gameCore/internal/config"
+	"gameCore/pkg/models"
+)
+
+// barrierSessions - игровые сессии в памяти. Создание сессии ждёт, пока
+// его не начнут wait входов, чтобы входы гарантированно шли параллельно
+type barrierSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*models.GameSession
+	arrived  sync.WaitGroup
+}
+
+func newBarrierSessions(wait int) *barrierSessions {
+	s := &barrierSessions{sessions: make(map[string]*models.GameSession)}
+	s.arrived.Add(wait)
+	return s
+}
+
+func (s *barrierSessions) GameSessionExists(ctx context.Context, gameID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[gameID]
+	return ok, nil
+}
+
+func (s *barrierSessions) CreateGameSession(ctx context.Context, session *models.GameSession) error {
+	s.arrived.Done()
+	s.arrived.Wait()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session.ID = uint(len(s.sessions) + 1)
+	s.sessions[session.GameID] = session
+	return nil
+}
+
+func (s *barrierSessions) GetGameSession(ctx context.Context, gameID string) (*models.GameSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[gameID]
+	if !ok {
+		return nil, errors.New("session not found")
+	}
+	return session, nil
+}
+
+func (s *barrierSessions) GetAllGameSessions(ctx context.Context) ([]models.GameSession, error) {
+	return nil, nil
+}
+
+func (s *barrierSessions) UpdateGameSession(ctx context.Context, session *models.GameSession) error {
+	return nil
+}
+
+func newTestRooms(t *testing.T) *RoomManager {
+	t.Helper()
+	m := NewRoomManager(nil, nil, nil, config.GameConfig{MaxPlayers: 2})
+	t.Cleanup(func() { m.Shutdown(context.Background()) })
+	return m
+}
+
+// Зритель видит весь мир, поэтому участник матча не может им стать, а
+// зритель - войти в игру, не закрыв трансляцию
+func TestSpectateRejectsPlayers(t *testing.T) {
+	m := newTestRooms(t)
+	ctx := context.Background()
+
+	g, err := m.JoinRoom(ctx, "", 1, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := m.CreateRoom(ctx, "other", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, roomID := range []string{g.ID, other.ID} {
+		if _, err := m.Spectate(roomID, 1, nil, 0); !errors.Is(err, ErrSpectatorInGame) {
+			t.Fatalf("комната %s: ожидалась ErrSpectatorInGame, получено %v", roomID, err)
+		}
+	}
+
+	// Ждущий переподключения игрок тоже остаётся участником матча
+	g.DisconnectPlayer(1, nil)
+	if _, err := m.Spectate(other.ID, 1, nil, 0); !errors.Is(err, ErrSpectatorInGame) {
+		t.Fatalf("отключённый игрок: ожидалась ErrSpectatorInGame, получено %v", err)
+	}
+
+	if _, err := m.Spectate(g.ID, 3, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.JoinRoom(ctx, "", 3, nil, ""); !errors.Is(err, ErrPlayerSpectates) {
+		t.Fatalf("ожидалась ErrPlayerSpectates, получено %v", err)
+	}
+	g.RemoveSpectator(3)
+	if _, err := m.JoinRoom(ctx, "", 3, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Два одновременных входа без свободных комнат попадают в одну комнату,
+// а лишняя созданная сессия закрывается
+func TestConcurrentMatchmakingSharesRoom(t *testing.T) {
+	sessions := newBarrierSessions(2)
+	m := NewRoomManager(sessions, nil, nil, config.GameConfig{MaxPlayers: 4})
+	t.Cleanup(func() { m.Shutdown(context.Background()) })
+
+	var wg sync.WaitGroup
+	joined := make([]*Game, 2)
+	for i := range joined {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g, err := m.JoinRoom(context.Background(), "", uint(i+1), nil, "")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			joined[i] = g
+		}(i)
+	}
+	wg.Wait()
+
+	if joined[0] == nil || joined[0] != joined[1] {
+		t.Fatal("игроки попали в разные комнаты")
+	}
+	if rooms := m.Rooms(); len(rooms) != 1 || rooms[0].Players != 2 {
+		t.Fatalf("ожидалась одна комната с двумя игроками, получено %+v", rooms)
+	}
+
+	ended := 0
+	for _, session := range sessions.sessions {
+		if !session.EndTime.IsZero() {
+			ended++
+		}
+	}
+	if ended != 1 {
+		t.Fatalf("закрыто сессий %d, ожидалась одна лишняя", ended)
+	}
+}
+
+// Отключённый античитом игрок не возвращается до конца запрета
+func TestKickCooldown(t *testing.T) {
+	tests := []struct {
+		name     string
+		cooldown time.Duration
+		rejoin   bool
+	}{
+		{"по умолчанию", 0, false},
+		{"запрет выключен", -1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewRoomManager(nil, nil, nil, config.GameConfig{AntiCheat: config.AntiCheatConfig{KickCooldown: tt.cooldown}})
+			t.Cleanup(func() { m.Shutdown(context.Background()) })
+			ctx := context.Background()
+
+			g, err := m.JoinRoom(ctx, "", 1, nil, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			m.KickPlayer(ctx, g, 1)
+
+			_, err = m.JoinRoom(ctx, "", 1, nil, "")
+			if tt.rejoin && err != nil {
+				t.Fatalf("возврат без запрета: %v", err)
+			}
+			if !tt.rejoin && !errors.Is(err, ErrKickCooldown) {
+				t.Fatalf("ожидалась ErrKickCooldown, получено %v", err)
+			}
+		})
+	}
+
+	// По истечении запрета игрок снова допускается
+	m := newTestRooms(t)
+	m.kick(1)
+	m.kicked[1] = time.Now().Add(-time.Second)
+	if _, err := m.JoinRoom(context.Background(), "", 1, nil, ""); err != nil {
+		t.Fatalf("запрет не истёк: %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package game
+
+import (
+	"container/heap"
+	"log"
+	"sort"
+	"time"
+
+	"gameCore/internal/protocol"
+)
+
+// entityRoom - владелец событий комнаты в целом, например конца матча
+const entityRoom entityKind = 255
+
+var roomOwner = entityRef{kind: entityRoom}
+
+// scheduledEvent - отложенное действие, которое выполнится внутри шага tick
+type scheduledEvent struct {
+	tick      uint64
+	order     uint64 // Порядок добавления: события одного тика идут по очереди
+	owner     entityRef
+	action    func()
+	cancelled bool
+}
+
+type eventQueue []*scheduledEvent
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].tick != q[j].tick {
+		return q[i].tick < q[j].tick
+	}
+	return q[i].order < q[j].order
+}
+
+func (q eventQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *eventQueue) Push(x any) { *q = append(*q, x.(*scheduledEvent)) }
+
+func (q *eventQueue) Pop() any {
+	old := *q
+	event := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return event
+}
+
+// scheduler хранит отложенные действия в куче по тику. В отличие от
+// time.AfterFunc действия выполняются в шаге симуляции под g.Mutex, в
+// детерминированном порядке, и отменяются вместе с владельцем
+type scheduler struct {
+	queue   eventQueue
+	byOwner map[entityRef][]*scheduledEvent
+	order   uint64
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{byOwner: make(map[entityRef][]*scheduledEvent)}
+}
+
+func (s *scheduler) schedule(tick uint64, owner entityRef, action func()) {
+	s.order++
+	event := &scheduledEvent{tick: tick, order: s.order, owner: owner, action: action}
+	heap.Push(&s.queue, event)
+	s.byOwner[owner] = append(s.byOwner[owner], event)
+}
+
+// cancelOwner отменяет все ожидающие действия владельца
+func (s *scheduler) cancelOwner(owner entityRef) {
+	for _, event := range s.byOwner[owner] {
+		event.cancelled = true
+	}
+	delete(s.byOwner, owner)
+}
+
+// runDue выполняет все действия, чей тик уже наступил
+func (s *scheduler) runDue(tick uint64) {
+	for len(s.queue) > 0 && s.queue[0].tick <= tick {
+		event := heap.Pop(&s.queue).(*scheduledEvent)
+		s.forget(event)
+		if !event.cancelled {
+			event.action()
+		}
+	}
+}
+
+func (s *scheduler) forget(event *scheduledEvent) {
+	events := s.byOwner[event.owner]
+	for i, e := range events {
+		if e == event {
+			events = append(events[:i], events[i+1:]...)
+			break
+		}
+	}
+	if len(events) == 0 {
+		delete(s.byOwner, event.owner)
+	} else {
+		s.byOwner[event.owner] = events
+	}
+}
+
+func (s *scheduler) clear() {
+	s.queue = nil
+	s.byOwner = make(map[entityRef][]*scheduledEvent)
+}
+
+// after планирует действие через d после текущего шага. Вызывается под g.Mutex
+func (g *Game) after(d time.Duration, owner entityRef, action func()) {
+	g.scheduler.schedule(g.Tick+g.durationToTicks(d), owner, action)
+}
+
+func playerOwner(id uint) entityRef {
+	return entityRef{kind: entityPlayer, id: id}
+}
+
+// WithMatchTime ограничивает матч по времени. onEnd вызывается из шага
+// симуляции под g.Mutex и не должен его захватывать
+func WithMatchTime(d time.Duration, onEnd func(*Game)) Option {
+	return func(g *Game) {
+		if d > 0 {
+			g.MatchTime = d
+			g.onMatchEnd = onEnd
+		}
+	}
+}
+
+// endMatch рассылает итоговую таблицу и сообщает владельцу комнаты о конце матча
+func (g *Game) endMatch() {
+	standings := g.standings()
+	log.Printf("Матч в комнате %s завершён на %v, игроков: %d", g.ID, g.Clock(), len(standings))
+
+	for _, player := range g.Players {
+		g.sendToPlayer(player, protocol.TypeMatchEnd, matchEndMessage{Standings: standings})
+	}
+	if g.onMatchEnd != nil {
+		g.onMatchEnd(g)
+	}
+}
+
+// standings - игроки по убыванию опыта
+func (g *Game) standings() []standing {
+	standings := make([]standing, 0, len(g.Players))
+	for _, player := range g.Players {
+		standings = append(standings, standing{ID: player.ID, Level: player.Level, XP: player.XP})
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		if standings[i].XP != standings[j].XP {
+			return standings[i].XP > standings[j].XP
+		}
+		return standings[i].ID < standings[j].ID
+	})
+	return standings
+}
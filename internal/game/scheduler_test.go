@@ -0,0 +1,94 @@
+package game
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// События выполняются по тику, а в одном тике - в порядке добавления
+func TestSchedulerRunsInTickOrder(t *testing.T) {
+	s := newScheduler()
+	var ran []string
+	add := func(tick uint64, name string) {
+		s.schedule(tick, roomOwner, func() { ran = append(ran, name) })
+	}
+	add(3, "c")
+	add(1, "a")
+	add(3, "d")
+	add(2, "b")
+	add(5, "e")
+
+	s.runDue(3)
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(ran, want) {
+		t.Fatalf("выполнено %v, ожидалось %v", ran, want)
+	}
+	s.runDue(4)
+	if len(ran) != 4 {
+		t.Fatalf("событие тика 5 выполнилось на тике 4: %v", ran)
+	}
+}
+
+func TestSchedulerCancelOwner(t *testing.T) {
+	s := newScheduler()
+	ran := make(map[uint]int)
+	for _, id := range []uint{1, 2} {
+		id := id
+		s.schedule(1, playerOwner(id), func() { ran[id]++ })
+		s.schedule(2, playerOwner(id), func() { ran[id]++ })
+	}
+
+	s.cancelOwner(playerOwner(1))
+	s.runDue(2)
+	if ran[1] != 0 || ran[2] != 2 {
+		t.Fatalf("выполнено %v: отмена должна касаться только владельца", ran)
+	}
+	if len(s.byOwner) != 0 {
+		t.Fatalf("после выполнения остались события владельцев: %v", s.byOwner)
+	}
+}
+
+// Возрождение погибшего игрока отменяется, если он вышел из комнаты
+func TestRemovePlayerCancelsRespawn(t *testing.T) {
+	g := NewGame(WithSeed(1))
+	if err := g.AddPlayer(1, nil); err != nil {
+		t.Fatal(err)
+	}
+	player := g.Players[1]
+	g.Mutex.Lock()
+	player.Die(g, 0)
+	g.Mutex.Unlock()
+
+	g.RemovePlayer(1)
+	if len(g.scheduler.byOwner[playerOwner(1)]) != 0 {
+		t.Fatal("после удаления игрока остались его события")
+	}
+
+	for i := uint64(0); i <= g.durationToTicks(RespawnTime); i++ {
+		g.step()
+	}
+	if player.Alive {
+		t.Fatal("удалённый игрок возродился")
+	}
+}
+
+// Остановка комнаты снимает все отложенные действия, включая конец матча
+func TestStopClearsScheduledEvents(t *testing.T) {
+	ended := false
+	g := NewGame(WithSeed(1), WithMatchTime(time.Second, func(*Game) { ended = true }))
+	g.Mutex.Lock()
+	g.Running = true
+	g.scheduleMatchEnd()
+	g.Mutex.Unlock()
+
+	g.Stop()
+	if len(g.scheduler.queue) != 0 {
+		t.Fatalf("после остановки в очереди %d событий", len(g.scheduler.queue))
+	}
+	for i := uint64(0); i <= g.durationToTicks(time.Second); i++ {
+		g.step()
+	}
+	if ended {
+		t.Fatal("матч остановленной комнаты закончился по таймеру")
+	}
+}
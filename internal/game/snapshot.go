@@ -0,0 +1,422 @@
+package game
+
+import (
+	"math"
+	"sort"
+
+	"gameCore/internal/protocol"
+)
+
+const (
+	KeyframeInterval = 60 // Полный снимок не реже, чем раз в столько снимков
+	SnapshotHistory  = 32 // Сколько неподтверждённых снимков храним на клиента
+)
+
+// worldState - полное состояние мира на тик. После сборки не изменяется,
+// поэтому одни и те же карты безопасно хранить в истории нескольких клиентов
+type worldState struct {
+	Players map[uint]playerState
+	Bullets map[uint]bulletState
+	Objects map[uint]objectState
+}
+
+// clientView хранит отправленные клиенту снимки. Дельта строится от
+// последнего снимка, который клиент подтвердил через PlayerInputData.Ack
+// или отдельным подтверждением AckSnapshot
+type clientView struct {
+	nextID        uint32
+	baselineID    uint32 // 0 - подтверждённого снимка нет
+	baseline      worldState
+	history       map[uint32]worldState
+	sinceKeyframe int
+
+	// Что клиент видел в прошлой рассылке - для событий входа/выхода из зоны
+	visiblePlayers map[uint]playerState
+	visibleObjects map[uint]objectState
+}
+
+func newClientView() *clientView {
+	return &clientView{history: make(map[uint32]worldState)}
+}
+
+// ack переносит базу на подтверждённый снимок и забывает более старые
+func (v *clientView) ack(id uint32) {
+	if id <= v.baselineID {
+		return
+	}
+	state, ok := v.history[id]
+	if !ok {
+		return
+	}
+
+	v.baselineID = id
+	v.baseline = state
+	for sent := range v.history {
+		if sent <= id {
+			delete(v.history, sent)
+		}
+	}
+}
+
+// build строит снимок для клиента: ключевой кадр, если базы нет или пора
+// обновить, иначе только изменившиеся сущности и поля
+func (v *clientView) build(world worldState, tick uint64) snapshotMessage {
+	v.nextID++
+	id := v.nextID
+
+	msg := snapshotMessage{ID: id, Tick: tick}
+
+	// Клиент хранит только последние SnapshotHistory снимков: более старая
+	// база у него уже забыта, и дельту от неё он применить не сможет
+	if v.baselineID != 0 && id-v.baselineID >= SnapshotHistory {
+		v.baselineID = 0
+		v.baseline = worldState{}
+	}
+
+	var base *worldState
+	if v.baselineID != 0 && v.sinceKeyframe < KeyframeInterval {
+		base = &v.baseline
+		msg.Baseline = v.baselineID
+		v.sinceKeyframe++
+	} else {
+		v.sinceKeyframe = 0
+	}
+
+	msg.Players, msg.Removed.Players = diffPlayers(base, world.Players)
+	msg.Bullets, msg.Removed.Bullets = diffBullets(base, world.Bullets)
+	msg.Objects, msg.Removed.Objects = diffObjects(base, world.Objects)
+
+	v.history[id] = world
+	if len(v.history) > SnapshotHistory {
+		delete(v.history, id-SnapshotHistory)
+	}
+	return msg
+}
+
+// snapshotMessage - нагрузка protocol.TypeState. Baseline == 0 означает
+// ключевой кадр: клиент заменяет мир целиком. Иначе клиент применяет дельту
+// к своему снимку Baseline и удаляет сущности из Removed
+type snapshotMessage struct {
+	ID       uint32          `json:"id"`
+	Baseline uint32          `json:"baseline"`
+	Tick     uint64          `json:"tick"`
+	Players  []playerDelta   `json:"players"`
+	Bullets  []bulletDelta   `json:"bullets"`
+	Objects  []objectDelta   `json:"objects"`
+	Removed  removedEntities `json:"removed"`
+}
+
+type removedEntities struct {
+	Players []uint `json:"players,omitempty"`
+	Bullets []uint `json:"bullets,omitempty"`
+	Objects []uint `json:"objects,omitempty"`
+}
+
+// Дельты содержат только изменившиеся поля: nil - поле не менялось
+type playerDelta struct {
+	ID          uint               `json:"id"`
+	X           *float64           `json:"x,omitempty"`
+	Y           *float64           `json:"y,omitempty"`
+	Angle       *float64           `json:"angle,omitempty"`
+	Level       *int               `json:"level,omitempty"`
+	NewLvlExp   *int               `json:"new_lvl_epx,omitempty"`
+	SkillPoints *int               `json:"skill_points,omitempty"`
+	Stats       map[string]float64 `json:"stats,omitempty"`
+	LastSeq     *uint32            `json:"last_seq,omitempty"`
+	Class       *string            `json:"class,omitempty"`
+	Protected   *bool              `json:"protected,omitempty"`
+	RTT         *int               `json:"rtt,omitempty"`
+}
+
+type bulletDelta struct {
+	ID     uint     `json:"id"`
+	X      *float64 `json:"x,omitempty"`
+	Y      *float64 `json:"y,omitempty"`
+	Angle  *float64 `json:"angle,omitempty"`
+	Radius *float64 `json:"radius,omitempty"`
+}
+
+type objectDelta struct {
+	ID     uint     `json:"id"`
+	X      *float64 `json:"x,omitempty"`
+	Y      *float64 `json:"y,omitempty"`
+	Health *int     `json:"health,omitempty"`
+	Radius *float64 `json:"radius,omitempty"`
+	Type   *string  `json:"type,omitempty"`
+}
+
+// changed возвращает указатель на cur, если значение отличается от базы
+func changed[T comparable](full bool, prev, cur T) *T {
+	if full || prev != cur {
+		return &cur
+	}
+	return nil
+}
+
+func diffPlayers(base *worldState, current map[uint]playerState) ([]playerDelta, []uint) {
+	deltas := make([]playerDelta, 0, len(current))
+	for _, id := range sortedIDs(current) {
+		cur := current[id]
+		prev, existed := playerState{}, false
+		if base != nil {
+			prev, existed = base.Players[id]
+		}
+		full := !existed
+
+		delta := playerDelta{
+			ID:          id,
+			X:           changed(full, prev.X, cur.X),
+			Y:           changed(full, prev.Y, cur.Y),
+			Angle:       changed(full, prev.Angle, cur.Angle),
+			Level:       changed(full, prev.Level, cur.Level),
+			NewLvlExp:   changed(full, prev.NewLvlExp, cur.NewLvlExp),
+			SkillPoints: changed(full, prev.SkillPoints, cur.SkillPoints),
+			Stats:       diffStats(full, prev.Stats, cur.Stats),
+			LastSeq:     changed(full, prev.LastSeq, cur.LastSeq),
+			Class:       changed(full, prev.Class, cur.Class),
+			Protected:   changed(full, prev.Protected, cur.Protected),
+			RTT:         changed(full, prev.RTT, cur.RTT),
+		}
+		if full || !delta.empty() {
+			deltas = append(deltas, delta)
+		}
+	}
+	return deltas, removedIDs(base, func(b *worldState) map[uint]playerState { return b.Players }, current)
+}
+
+func (d playerDelta) empty() bool {
+	return d.X == nil && d.Y == nil && d.Angle == nil && d.Level == nil &&
+		d.NewLvlExp == nil && d.SkillPoints == nil && len(d.Stats) == 0 &&
+		d.LastSeq == nil && d.Class == nil && d.Protected == nil && d.RTT == nil
+}
+
+func diffStats(full bool, prev, cur map[string]float64) map[string]float64 {
+	if full {
+		return cur
+	}
+	var delta map[string]float64
+	for key, value := range cur {
+		if old, ok := prev[key]; !ok || old != value {
+			if delta == nil {
+				delta = make(map[string]float64)
+			}
+			delta[key] = value
+		}
+	}
+	return delta
+}
+
+func diffBullets(base *worldState, current map[uint]bulletState) ([]bulletDelta, []uint) {
+	deltas := make([]bulletDelta, 0, len(current))
+	for _, id := range sortedIDs(current) {
+		cur := current[id]
+		prev, existed := bulletState{}, false
+		if base != nil {
+			prev, existed = base.Bullets[id]
+		}
+		full := !existed
+
+		delta := bulletDelta{
+			ID:     id,
+			X:      changed(full, prev.X, cur.X),
+			Y:      changed(full, prev.Y, cur.Y),
+			Angle:  changed(full, prev.Angle, cur.Angle),
+			Radius: changed(full, prev.Radius, cur.Radius),
+		}
+		if full || delta.X != nil || delta.Y != nil || delta.Angle != nil || delta.Radius != nil {
+			deltas = append(deltas, delta)
+		}
+	}
+	return deltas, removedIDs(base, func(b *worldState) map[uint]bulletState { return b.Bullets }, current)
+}
+
+func diffObjects(base *worldState, current map[uint]objectState) ([]objectDelta, []uint) {
+	deltas := make([]objectDelta, 0, len(current))
+	for _, id := range sortedIDs(current) {
+		cur := current[id]
+		prev, existed := objectState{}, false
+		if base != nil {
+			prev, existed = base.Objects[id]
+		}
+		full := !existed
+
+		delta := objectDelta{
+			ID:     id,
+			X:      changed(full, prev.X, cur.X),
+			Y:      changed(full, prev.Y, cur.Y),
+			Health: changed(full, prev.Health, cur.Health),
+			Radius: changed(full, prev.Radius, cur.Radius),
+			Type:   changed(full, prev.Type, cur.Type),
+		}
+		if full || delta.X != nil || delta.Y != nil || delta.Health != nil ||
+			delta.Radius != nil || delta.Type != nil {
+			deltas = append(deltas, delta)
+		}
+	}
+	return deltas, removedIDs(base, func(b *worldState) map[uint]objectState { return b.Objects }, current)
+}
+
+// removedIDs возвращает сущности, которые были в базе, но исчезли из мира
+func removedIDs[T any](base *worldState, pick func(*worldState) map[uint]T, current map[uint]T) []uint {
+	if base == nil {
+		return nil
+	}
+	var removed []uint
+	for _, id := range sortedIDs(pick(base)) {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	return removed
+}
+
+func sortedIDs[T any](entities map[uint]T) []uint {
+	ids := make([]uint, 0, len(entities))
+	for id := range entities {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Бинарный формат: у каждой сущности id u32 и маска u16 присутствующих полей
+const (
+	fieldX uint16 = 1 << iota
+	fieldY
+	fieldAngle
+	fieldLevel
+	fieldNewLvlExp
+	fieldSkillPoints
+	fieldStats
+	fieldHealth
+	fieldLastSeq
+	fieldClass
+	fieldRadius
+	fieldType
+	fieldProtected
+	fieldRTT
+)
+
+func (m snapshotMessage) MarshalBinaryTo(w *protocol.Writer) {
+	w.Uint32(m.ID)
+	w.Uint32(m.Baseline)
+	w.Uint64(m.Tick)
+
+	w.Uint16(uint16(len(m.Players)))
+	for _, d := range m.Players {
+		d.MarshalBinaryTo(w)
+	}
+	w.Uint16(uint16(len(m.Bullets)))
+	for _, d := range m.Bullets {
+		d.MarshalBinaryTo(w)
+	}
+	w.Uint16(uint16(len(m.Objects)))
+	for _, d := range m.Objects {
+		d.MarshalBinaryTo(w)
+	}
+
+	writeIDs(w, m.Removed.Players)
+	writeIDs(w, m.Removed.Bullets)
+	writeIDs(w, m.Removed.Objects)
+}
+
+func (d playerDelta) MarshalBinaryTo(w *protocol.Writer) {
+	var mask uint16
+	mask |= maskIf(d.X != nil, fieldX)
+	mask |= maskIf(d.Y != nil, fieldY)
+	mask |= maskIf(d.Angle != nil, fieldAngle)
+	mask |= maskIf(d.Level != nil, fieldLevel)
+	mask |= maskIf(d.NewLvlExp != nil, fieldNewLvlExp)
+	mask |= maskIf(d.SkillPoints != nil, fieldSkillPoints)
+	mask |= maskIf(len(d.Stats) > 0, fieldStats)
+	mask |= maskIf(d.LastSeq != nil, fieldLastSeq)
+	mask |= maskIf(d.Class != nil, fieldClass)
+	mask |= maskIf(d.Protected != nil, fieldProtected)
+	mask |= maskIf(d.RTT != nil, fieldRTT)
+
+	w.Uint32(uint32(d.ID))
+	w.Uint16(mask)
+	writeFloat(w, d.X)
+	writeFloat(w, d.Y)
+	writeFloat(w, d.Angle)
+	if d.Level != nil {
+		w.Uint16(uint16(*d.Level))
+	}
+	if d.NewLvlExp != nil {
+		w.Uint32(uint32(*d.NewLvlExp))
+	}
+	if d.SkillPoints != nil {
+		w.Uint16(uint16(*d.SkillPoints))
+	}
+	if len(d.Stats) > 0 {
+		writeStats(w, d.Stats)
+	}
+	if d.LastSeq != nil {
+		w.Uint32(*d.LastSeq)
+	}
+	if d.Class != nil {
+		w.String(*d.Class)
+	}
+	if d.Protected != nil {
+		w.Bool(*d.Protected)
+	}
+	if d.RTT != nil {
+		w.Uint16(uint16(min(*d.RTT, math.MaxUint16)))
+	}
+}
+
+func (d bulletDelta) MarshalBinaryTo(w *protocol.Writer) {
+	var mask uint16
+	mask |= maskIf(d.X != nil, fieldX)
+	mask |= maskIf(d.Y != nil, fieldY)
+	mask |= maskIf(d.Angle != nil, fieldAngle)
+	mask |= maskIf(d.Radius != nil, fieldRadius)
+
+	w.Uint32(uint32(d.ID))
+	w.Uint16(mask)
+	writeFloat(w, d.X)
+	writeFloat(w, d.Y)
+	writeFloat(w, d.Angle)
+	writeFloat(w, d.Radius)
+}
+
+func (d objectDelta) MarshalBinaryTo(w *protocol.Writer) {
+	var mask uint16
+	mask |= maskIf(d.X != nil, fieldX)
+	mask |= maskIf(d.Y != nil, fieldY)
+	mask |= maskIf(d.Health != nil, fieldHealth)
+	mask |= maskIf(d.Radius != nil, fieldRadius)
+	mask |= maskIf(d.Type != nil, fieldType)
+
+	w.Uint32(uint32(d.ID))
+	w.Uint16(mask)
+	writeFloat(w, d.X)
+	writeFloat(w, d.Y)
+	if d.Health != nil {
+		w.Int32(int32(*d.Health))
+	}
+	writeFloat(w, d.Radius)
+	if d.Type != nil {
+		w.String(*d.Type)
+	}
+}
+
+func maskIf(present bool, bit uint16) uint16 {
+	if present {
+		return bit
+	}
+	return 0
+}
+
+func writeFloat(w *protocol.Writer, v *float64) {
+	if v != nil {
+		w.Float32(*v)
+	}
+}
+
+func writeIDs(w *protocol.Writer, ids []uint) {
+	w.Uint16(uint16(len(ids)))
+	for _, id := range ids {
+		w.Uint32(uint32(id))
+	}
+}
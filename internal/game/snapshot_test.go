@@ -0,0 +1,100 @@
+package game
+
+import (
+	"testing"
+
+	"gameCore/internal/protocol"
+)
+
+// snapshotConn запоминает снимки, отправленные игроку
+type snapshotConn struct {
+	snapshots []snapshotMessage
+}
+
+func (c *snapshotConn) Send(msgType protocol.MessageType, payload interface{}) error {
+	if snapshot, ok := payload.(snapshotMessage); ok {
+		c.snapshots = append(c.snapshots, snapshot)
+	}
+	return nil
+}
+
+func (c *snapshotConn) Close() {}
+
+// snapshotClient повторяет хранение снимков веб-клиента
+// (shooter-game/src/utils/snapshot.js): помнит последние SnapshotHistory
+// снимков и не может применить дельту от забытой базы
+type snapshotClient struct {
+	history map[uint32]bool
+}
+
+func (c *snapshotClient) apply(msg snapshotMessage) bool {
+	if msg.Baseline != 0 && !c.history[msg.Baseline] {
+		return false
+	}
+	c.history[msg.ID] = true
+	for id := range c.history {
+		if id+SnapshotHistory <= msg.ID {
+			delete(c.history, id)
+		}
+	}
+	return true
+}
+
+func newIdleGame(t *testing.T) (*Game, *snapshotConn) {
+	t.Helper()
+	g := NewGame(WithSeed(1))
+	conn := &snapshotConn{}
+	if err := g.AddPlayer(1, conn); err != nil {
+		t.Fatal(err)
+	}
+	return g, conn
+}
+
+// Клиент без ввода подтвердил только первый снимок и дальше молчит:
+// каждый следующий снимок должен оставаться применимым
+func TestIdleClientReceivesApplicableSnapshots(t *testing.T) {
+	g, conn := newIdleGame(t)
+	client := &snapshotClient{history: make(map[uint32]bool)}
+
+	g.step()
+	g.broadcastState()
+	first := conn.snapshots[0]
+	if !client.apply(first) {
+		t.Fatal("первый снимок должен быть ключевым кадром")
+	}
+	g.AckSnapshot(1, first.ID)
+
+	for i := 0; i < 4*KeyframeInterval; i++ {
+		g.step()
+		g.broadcastState()
+	}
+
+	for _, msg := range conn.snapshots[1:] {
+		if !client.apply(msg) {
+			t.Fatalf("снимок %d построен от базы %d, которую клиент уже забыл", msg.ID, msg.Baseline)
+		}
+	}
+}
+
+// Отдельное подтверждение без ввода сдвигает базу дельта-сжатия, и
+// ключевые кадры идут только по расписанию
+func TestAckSnapshotAdvancesBaseline(t *testing.T) {
+	g, conn := newIdleGame(t)
+
+	const snapshots = 3 * KeyframeInterval
+	for i := 0; i < snapshots; i++ {
+		g.step()
+		g.broadcastState()
+		g.AckSnapshot(1, conn.snapshots[len(conn.snapshots)-1].ID)
+	}
+
+	keyframes := 0
+	for _, msg := range conn.snapshots {
+		if msg.Baseline == 0 {
+			keyframes++
+		}
+	}
+	if limit := snapshots/KeyframeInterval + 1; keyframes > limit {
+		t.Fatalf("ключевых кадров %d из %d снимков, ожидалось не больше %d", keyframes, snapshots, limit)
+	}
+}
@@ -0,0 +1,65 @@
+package game
+
+import "math"
+
+type cellKey struct {
+	X, Y int
+}
+
+type gridItem[T any] struct {
+	x, y  float64
+	value T
+}
+
+// spatialGrid - равномерная сетка для поиска сущностей рядом с точкой.
+// Запросы возвращают кандидатов из пересекаемых ячеек, точную проверку
+// расстояния делает вызывающий код
+type spatialGrid[T any] struct {
+	cellSize float64
+	cells    map[cellKey][]gridItem[T]
+}
+
+func newSpatialGrid[T any](cellSize float64) *spatialGrid[T] {
+	return &spatialGrid[T]{
+		cellSize: cellSize,
+		cells:    make(map[cellKey][]gridItem[T]),
+	}
+}
+
+func (g *spatialGrid[T]) key(x, y float64) cellKey {
+	return cellKey{
+		X: int(math.Floor(x / g.cellSize)),
+		Y: int(math.Floor(y / g.cellSize)),
+	}
+}
+
+func (g *spatialGrid[T]) Insert(x, y float64, value T) {
+	key := g.key(x, y)
+	g.cells[key] = append(g.cells[key], gridItem[T]{x: x, y: y, value: value})
+}
+
+// Clear очищает сетку, сохраняя выделенные ячейки для следующего тика
+func (g *spatialGrid[T]) Clear() {
+	for key, items := range g.cells {
+		g.cells[key] = items[:0]
+	}
+}
+
+// QueryRect обходит сущности в ячейках, пересекающих прямоугольник
+func (g *spatialGrid[T]) QueryRect(minX, minY, maxX, maxY float64, fn func(x, y float64, value T)) {
+	from := g.key(minX, minY)
+	to := g.key(maxX, maxY)
+
+	for cx := from.X; cx <= to.X; cx++ {
+		for cy := from.Y; cy <= to.Y; cy++ {
+			for _, item := range g.cells[cellKey{X: cx, Y: cy}] {
+				fn(item.x, item.y, item.value)
+			}
+		}
+	}
+}
+
+// Query обходит сущности в ячейках вокруг окружности (x, y, radius)
+func (g *spatialGrid[T]) Query(x, y, radius float64, fn func(x, y float64, value T)) {
+	g.QueryRect(x-radius, y-radius, x+radius, y+radius, fn)
+}
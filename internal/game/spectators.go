@@ -0,0 +1,99 @@
+package game
+
+import (
+	"log"
+)
+
+// Spectator получает снимки комнаты, но не занимает место игрока и не
+// влияет на симуляцию
+type Spectator struct {
+	ID     uint
+	Conn   Connection
+	Follow uint // ID игрока, за чьей камерой следить, 0 - весь мир
+	view   *clientView
+}
+
+// SpectatorInputData - единственное, что может прислать зритель
+type SpectatorInputData struct {
+	Ack    uint32 `json:"ack"`    // ID последнего полученного снимка
+	Follow uint   `json:"follow"` // Смена отслеживаемого игрока, 0 - весь мир
+}
+
+// AddSpectator подключает зрителя. Лимит MaxPlayers на зрителей не действует
+func (g *Game) AddSpectator(id uint, conn Connection, follow uint) error {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	if _, exists := g.Spectators[id]; exists {
+		return ErrSpectatorExists
+	}
+
+	g.Spectators[id] = &Spectator{
+		ID:     id,
+		Conn:   conn,
+		Follow: follow,
+		view:   newClientView(),
+	}
+	log.Printf("Добавлен зритель %d", id)
+	return nil
+}
+
+// UpdateSpectator принимает подтверждение снимка и смену камеры зрителя
+func (g *Game) UpdateSpectator(id uint, input SpectatorInputData) {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	spectator, exists := g.Spectators[id]
+	if !exists {
+		return
+	}
+	spectator.view.ack(input.Ack)
+	spectator.Follow = input.Follow
+}
+
+func (g *Game) RemoveSpectator(id uint) {
+	g.Mutex.Lock()
+	defer g.Mutex.Unlock()
+
+	if spectator, exists := g.Spectators[id]; exists {
+		if spectator.Conn != nil {
+			spectator.Conn.Close()
+		}
+		delete(g.Spectators, id)
+		log.Printf("Зритель %d удален", id)
+	}
+}
+
+// HasSpectator сообщает, смотрит ли пользователь id эту комнату
+func (g *Game) HasSpectator(id uint) bool {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+
+	_, exists := g.Spectators[id]
+	return exists
+}
+
+// SpectatorCount возвращает текущее количество зрителей в комнате
+func (g *Game) SpectatorCount() int {
+	g.Mutex.RLock()
+	defer g.Mutex.RUnlock()
+	return len(g.Spectators)
+}
+
+// broadcastSpectators вызывается из broadcastState под g.Mutex. Зритель с
+// камерой видит то же, что отслеживаемый игрок, остальные - весь мир
+func (g *Game) broadcastSpectators(world worldState, grid *spatialGrid[entityRef]) {
+	for _, s := range g.Spectators {
+		if s.Conn == nil {
+			continue
+		}
+
+		visible := world
+		if target, ok := g.Players[s.Follow]; ok {
+			visible = world.visibleFrom(grid, target.X, target.Y, g.ViewRadius)
+		}
+		if err := g.sendWorld(s.Conn, s.view, visible); err != nil {
+			log.Printf("❌ Ошибка отправки состояния зрителю %d: %v", s.ID, err)
+		}
+	}
+}
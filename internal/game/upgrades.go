@@ -0,0 +1,227 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"gameCore/internal/config"
+	"gameCore/internal/protocol"
+)
+
+// DefaultUpgrades - каталог улучшений по умолчанию
+var DefaultUpgrades = []config.UpgradeConfig{
+	{Name: "health", DisplayName: "Здоровье", Stats: []string{"max_health", "health"}, Increment: 15, Decay: 0.9, MaxLevel: 8},
+	{Name: "damage", DisplayName: "Урон", Stats: []string{"damage"}, Increment: 5, Decay: 0.9, MaxLevel: 8},
+	{Name: "speed", DisplayName: "Скорость", Stats: []string{"speed"}, Increment: 24, Decay: 0.85, MaxLevel: 8},
+	{Name: "reload", DisplayName: "Перезарядка", Stats: []string{"fire_rate"}, Increment: 0.5, Decay: 0.9, MaxLevel: 8},
+	{Name: "bullet_speed", DisplayName: "Скорость пули", Stats: []string{"bullet_speed"}, Increment: 60, Decay: 0.9, MaxLevel: 8},
+	{Name: "body_damage", DisplayName: "Таранный урон", Stats: []string{"body_damage"}, Increment: 5, Decay: 0.9, MaxLevel: 8},
+}
+
+// UpgradeInfo описывает улучшение в каталоге, который получает клиент
+type UpgradeInfo struct {
+	Name        string    `json:"name"`
+	DisplayName string    `json:"display_name"`
+	Stats       []string  `json:"stats"`
+	MaxLevel    int       `json:"max_level"`
+	Increments  []float64 `json:"increments"` // Прибавка на каждом уровне
+}
+
+// UpgradeCatalogue - нагрузка protocol.TypeUpgradeCatalogue, отправляется при подключении
+type UpgradeCatalogue struct {
+	Upgrades []UpgradeInfo `json:"upgrades"`
+}
+
+func (c UpgradeCatalogue) MarshalBinaryTo(w *protocol.Writer) {
+	w.Uint8(uint8(len(c.Upgrades)))
+	for _, u := range c.Upgrades {
+		w.String(u.Name)
+		w.String(u.DisplayName)
+		w.Uint8(uint8(len(u.Stats)))
+		for _, stat := range u.Stats {
+			w.String(stat)
+		}
+		w.Uint8(uint8(u.MaxLevel))
+		for _, inc := range u.Increments {
+			w.Float32(inc)
+		}
+	}
+}
+
+// upgradeSet - каталог улучшений комнаты с поиском по имени
+type upgradeSet struct {
+	catalogue UpgradeCatalogue
+	byName    map[string]*UpgradeInfo
+}
+
+func newUpgradeSet(defs []config.UpgradeConfig) (*upgradeSet, error) {
+	set := &upgradeSet{
+		catalogue: UpgradeCatalogue{Upgrades: make([]UpgradeInfo, 0, len(defs))},
+		byName:    make(map[string]*UpgradeInfo, len(defs)),
+	}
+
+	for _, def := range defs {
+		if def.Name == "" || len(def.Stats) == 0 || def.MaxLevel <= 0 || def.Increment == 0 {
+			return nil, fmt.Errorf("%w: улучшение %q задано не полностью", ErrInvalidUpgrades, def.Name)
+		}
+		decay := def.Decay
+		if decay <= 0 {
+			decay = 1
+		}
+
+		info := UpgradeInfo{
+			Name:        def.Name,
+			DisplayName: def.DisplayName,
+			Stats:       def.Stats,
+			MaxLevel:    def.MaxLevel,
+			Increments:  make([]float64, def.MaxLevel),
+		}
+		for level := range info.Increments {
+			info.Increments[level] = def.Increment * math.Pow(decay, float64(level))
+		}
+		set.catalogue.Upgrades = append(set.catalogue.Upgrades, info)
+	}
+
+	for i := range set.catalogue.Upgrades {
+		info := &set.catalogue.Upgrades[i]
+		if _, exists := set.byName[info.Name]; exists {
+			return nil, fmt.Errorf("%w: улучшение %s объявлено дважды", ErrInvalidUpgrades, info.Name)
+		}
+		set.byName[info.Name] = info
+	}
+	return set, nil
+}
+
+// WithUpgrades задаёт каталог улучшений комнаты. Некорректный каталог
+// отбрасывается, остаётся каталог по умолчанию
+func WithUpgrades(defs []config.UpgradeConfig) Option {
+	return func(g *Game) {
+		if len(defs) == 0 {
+			return
+		}
+		set, err := newUpgradeSet(defs)
+		if err != nil {
+			log.Printf("Каталог улучшений комнаты %s не загружен: %v", g.ID, err)
+			return
+		}
+		g.upgrades = set
+	}
+}
+
+// UpgradeCatalogue возвращает каталог улучшений комнаты
+func (g *Game) UpgradeCatalogue() UpgradeCatalogue {
+	return g.upgrades.catalogue
+}
+
+// upgradeRejectedMessage - нагрузка protocol.TypeUpgradeRejected
+type upgradeRejectedMessage struct {
+	Upgrade string `json:"upgrade"`
+	Reason  string `json:"reason"`
+}
+
+func (m upgradeRejectedMessage) MarshalBinaryTo(w *protocol.Writer) {
+	w.String(m.Upgrade)
+	w.String(m.Reason)
+}
+
+// rejectReason переводит ошибку улучшения в код для клиента
+func rejectReason(err error) string {
+	switch {
+	case errors.Is(err, ErrUnknownUpgrade):
+		return "unknown_upgrade"
+	case errors.Is(err, ErrNoSkillPoints):
+		return "no_skill_points"
+	case errors.Is(err, ErrUpgradeMaxed):
+		return "max_level"
+	case errors.Is(err, ErrStatCapped):
+		return "class_cap"
+	default:
+		return "rejected"
+	}
+}
+
+// handleUpgrade тратит очко навыка на улучшение name, а при отказе сообщает
+// клиенту причину. Вызывается под g.Mutex
+func (g *Game) handleUpgrade(player *Player, name string) {
+	if err := g.applyUpgrade(player, name); err != nil {
+		log.Printf("Игрок %d не может улучшить %s: %v", player.ID, name, err)
+		g.sendToPlayer(player, protocol.TypeUpgradeRejected, upgradeRejectedMessage{
+			Upgrade: name,
+			Reason:  rejectReason(err),
+		})
+		return
+	}
+
+	g.sendPlayerUpdate(player)
+
+	log.Printf("Игрок %d улучшил %s до уровня %d. Осталось очков: %d",
+		player.ID, name, player.Upgrades[name], player.SkillPoints)
+}
+
+func (g *Game) applyUpgrade(player *Player, name string) error {
+	upgrade, exists := g.upgrades.byName[name]
+	if !exists {
+		return ErrUnknownUpgrade
+	}
+	if player.SkillPoints <= 0 {
+		return ErrNoSkillPoints
+	}
+	level := player.Upgrades[name]
+	if level >= upgrade.MaxLevel {
+		return ErrUpgradeMaxed
+	}
+	for _, stat := range upgrade.Stats {
+		if player.atCap(stat) {
+			return ErrStatCapped
+		}
+	}
+
+	increment := upgrade.Increments[level]
+	for _, stat := range upgrade.Stats {
+		value := player.Stats[stat] + increment
+		if limit, ok := player.class.StatCaps[stat]; ok {
+			value = math.Min(value, limit)
+		}
+		player.Stats[stat] = value
+	}
+
+	player.Upgrades[name] = level + 1
+	player.SkillPoints--
+	return nil
+}
+
+// sendPlayerUpdate вызывается под g.Mutex и только ставит сообщение в очередь
+func (g *Game) sendPlayerUpdate(player *Player) {
+	g.sendToPlayer(player, protocol.TypeUpgrade, upgradeMessage{
+		SkillPoints: player.SkillPoints,
+		Stats:       player.Stats,
+		Levels:      player.Upgrades,
+	})
+}
+
+func (g *Game) sendToPlayer(player *Player, msgType protocol.MessageType, payload interface{}) {
+	if player.Conn == nil {
+		return
+	}
+	if err := player.Conn.Send(msgType, payload); err != nil {
+		log.Printf("Ошибка отправки %s игроку %d: %v", msgType, player.ID, err)
+	}
+}
+
+func mustUpgradeSet(defs []config.UpgradeConfig) *upgradeSet {
+	set, err := newUpgradeSet(defs)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+var defaultUpgradeSet = mustUpgradeSet(DefaultUpgrades)
+
+// HasUpgrade сообщает, есть ли улучшение name в каталоге комнаты
+func (g *Game) HasUpgrade(name string) bool {
+	_, ok := g.upgrades.byName[name]
+	return ok
+}
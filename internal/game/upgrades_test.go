@@ -0,0 +1,116 @@
+package game
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"gameCore/internal/config"
+)
+
+// Прибавка каждого следующего уровня убывает в Decay раз
+func TestUpgradeIncrementCurve(t *testing.T) {
+	tests := []struct {
+		name string
+		def  config.UpgradeConfig
+		want []float64
+	}{
+		{
+			"убывающая отдача",
+			config.UpgradeConfig{Name: "damage", Stats: []string{"damage"}, Increment: 8, Decay: 0.5, MaxLevel: 4},
+			[]float64{8, 4, 2, 1},
+		},
+		{
+			"без Decay прибавка постоянна",
+			config.UpgradeConfig{Name: "speed", Stats: []string{"speed"}, Increment: 3, MaxLevel: 3},
+			[]float64{3, 3, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set, err := newUpgradeSet([]config.UpgradeConfig{tt.def})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := set.byName[tt.def.Name].Increments
+			if len(got) != len(tt.want) {
+				t.Fatalf("уровней %d, ожидалось %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Fatalf("уровень %d: прибавка %v, ожидалось %v", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewUpgradeSetRejectsInvalid(t *testing.T) {
+	valid := config.UpgradeConfig{Name: "damage", Stats: []string{"damage"}, Increment: 1, MaxLevel: 1}
+	tests := []struct {
+		name string
+		defs []config.UpgradeConfig
+	}{
+		{"без имени", []config.UpgradeConfig{{Stats: []string{"damage"}, Increment: 1, MaxLevel: 1}}},
+		{"без статов", []config.UpgradeConfig{{Name: "damage", Increment: 1, MaxLevel: 1}}},
+		{"без уровней", []config.UpgradeConfig{{Name: "damage", Stats: []string{"damage"}, Increment: 1}}},
+		{"без прибавки", []config.UpgradeConfig{{Name: "damage", Stats: []string{"damage"}, MaxLevel: 1}}},
+		{"дубликат", []config.UpgradeConfig{valid, valid}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newUpgradeSet(tt.defs); !errors.Is(err, ErrInvalidUpgrades) {
+				t.Fatalf("ожидалась ErrInvalidUpgrades, получено %v", err)
+			}
+		})
+	}
+}
+
+func TestApplyUpgrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		upgrade string
+		class   string
+		points  int
+		levels  int // Сколько уровней уже куплено
+		stat    string
+		start   float64
+		want    float64
+		err     error
+	}{
+		{"первый уровень", "damage", "basic", 1, 0, "damage", 10, 15, nil},
+		{"прибавка убывает", "damage", "basic", 1, 1, "damage", 15, 19.5, nil},
+		{"неизвестное улучшение", "jump", "basic", 1, 0, "damage", 10, 10, ErrUnknownUpgrade},
+		{"нет очков навыка", "damage", "basic", 0, 0, "damage", 10, 10, ErrNoSkillPoints},
+		{"максимальный уровень", "damage", "basic", 1, 8, "damage", 10, 10, ErrUpgradeMaxed},
+		{"прибавка срезается пределом класса", "reload", "sniper", 1, 0, "fire_rate", 3.8, 4, nil},
+		{"стат уже на пределе класса", "reload", "sniper", 1, 0, "fire_rate", 4, 4, ErrStatCapped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewGame(WithSeed(1))
+			if err := g.AddPlayer(1, nil); err != nil {
+				t.Fatal(err)
+			}
+			player := g.Players[1]
+			player.class = g.classes.classes[tt.class]
+			player.SkillPoints = tt.points
+			player.Upgrades[tt.upgrade] = tt.levels
+			player.Stats[tt.stat] = tt.start
+
+			err := g.applyUpgrade(player, tt.upgrade)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ошибка %v, ожидалась %v", err, tt.err)
+			}
+			if got := player.Stats[tt.stat]; math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("%s = %v, ожидалось %v", tt.stat, got, tt.want)
+			}
+			if err == nil && (player.SkillPoints != tt.points-1 || player.Upgrades[tt.upgrade] != tt.levels+1) {
+				t.Fatalf("очков %d, уровень %d после улучшения", player.SkillPoints, player.Upgrades[tt.upgrade])
+			}
+		})
+	}
+}
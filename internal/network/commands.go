@@ -0,0 +1,292 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"gameCore/internal/game"
+	"gameCore/internal/protocol"
+)
+
+const (
+	legacyCommand = "input" // Сообщение без поля type - прежний формат PlayerInputData
+	maxChatLength = 200     // Символов в сообщении чата
+)
+
+// commandHeader - поля, общие для всех входящих сообщений игрока
+type commandHeader struct {
+	Type     string `json:"type"`
+	Seq      uint32 `json:"seq"`       // Монотонный номер сообщения
+	Ack      uint32 `json:"ack"`       // ID последнего полученного клиентом снимка
+	ViewTick uint64 `json:"view_tick"` // Тик снимка, который клиент видит сейчас
+}
+
+// command - входящее сообщение игрока. Поля команды разбираются из того же
+// JSON-объекта, что и заголовок. validate проверяет схему до обращения к
+// комнате, handle применяет команду
+type command interface {
+	validate(c *commandContext) error
+	handle(c *commandContext) error
+}
+
+// commandSpec описывает зарегистрированный тип входящего сообщения
+type commandSpec struct {
+	reliable bool           // Сообщение расходует лимит команд, а не движения
+	decode   func() command // Пустое значение команды для разбора
+}
+
+// commands - обработчики входящих сообщений по полю type. Новая команда
+// добавляется сюда и не затрагивает разбор движения
+var commands = map[string]commandSpec{
+	legacyCommand:     {reliable: false, decode: func() command { return &inputCommand{} }},
+	"move":            {reliable: false, decode: func() command { return &moveCommand{} }},
+	"aim":             {reliable: false, decode: func() command { return &aimCommand{} }},
+	"shoot":           {reliable: false, decode: func() command { return &shootCommand{} }},
+	"ping":            {reliable: false, decode: func() command { return &pingCommand{} }},
+	"ack":             {reliable: false, decode: func() command { return &ackCommand{} }},
+	"upgrade":         {reliable: true, decode: func() command { return &upgradeCommand{} }},
+	"choose_class":    {reliable: true, decode: func() command { return &chooseClassCommand{} }},
+	"chat":            {reliable: true, decode: func() command { return &chatCommand{} }},
+	"respawn_request": {reliable: true, decode: func() command { return &respawnCommand{} }},
+}
+
+// commandError - отказ в команде, о котором сообщаем клиенту. Соединение
+// при этом остаётся открытым
+type commandError struct {
+	Code    string
+	Message string
+}
+
+func (e *commandError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func rejectf(code, format string, args ...interface{}) error {
+	return &commandError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// commandContext - состояние разбора сообщений одного соединения игрока
+type commandContext struct {
+	game   *game.Game
+	conn   *Conn
+	userID uint
+	header commandHeader // Заголовок текущего сообщения
+}
+
+// decode определяет тип сообщения и разбирает его в команду
+func (c *commandContext) decode(raw []byte) (command, error) {
+	c.header = commandHeader{}
+	if err := json.Unmarshal(raw, &c.header); err != nil {
+		return nil, rejectf("invalid_payload", "malformed message: %v", err)
+	}
+	if c.header.Type == "" {
+		c.header.Type = legacyCommand
+	}
+
+	spec, ok := commands[c.header.Type]
+	if !ok {
+		return nil, rejectf("unknown_command", "unknown message type %q", c.header.Type)
+	}
+	cmd := spec.decode()
+	if err := json.Unmarshal(raw, cmd); err != nil {
+		return nil, rejectf("invalid_payload", "malformed %s: %v", c.header.Type, err)
+	}
+	return cmd, nil
+}
+
+// reliable сообщает, по какому лимиту считать сообщение. Неразобранное
+// сообщение расходует лимит движения
+func (c *commandContext) reliable(cmd command) bool {
+	// Старый формат несёт и движение, и команды в одном сообщении
+	if input, ok := cmd.(*inputCommand); ok {
+		return input.HasCommand()
+	}
+	return cmd != nil && commands[c.header.Type].reliable
+}
+
+func (c *commandContext) run(cmd command) error {
+	if err := cmd.validate(c); err != nil {
+		return err
+	}
+	return cmd.handle(c)
+}
+
+// reject отвечает клиенту структурированной ошибкой по сообщению
+func (c *commandContext) reject(err *commandError) {
+	c.conn.Send(protocol.TypeError, protocol.Error{
+		Code:    err.Code,
+		Message: err.Message,
+		Command: c.header.Type,
+		Seq:     c.header.Seq,
+	})
+}
+
+// update передаёт в комнату поля fields ввода вместе с заголовком сообщения
+func (c *commandContext) update(data game.PlayerInputData, fields game.InputField) error {
+	data.Seq, data.Ack, data.ViewTick = c.header.Seq, c.header.Ack, c.header.ViewTick
+	return c.game.UpdateInput(game.PlayerInput{ID: c.userID, Input: data}, fields)
+}
+
+// inputCommand - прежний формат: движение, прицел, выстрел и команды разом
+type inputCommand struct {
+	game.PlayerInputData
+}
+
+func (m *inputCommand) validate(c *commandContext) error { return nil }
+
+func (m *inputCommand) handle(c *commandContext) error {
+	return c.game.SubmitInput(game.PlayerInput{ID: c.userID, Input: m.PlayerInputData})
+}
+
+type moveCommand struct {
+	Up    bool `json:"up"`
+	Down  bool `json:"down"`
+	Left  bool `json:"left"`
+	Right bool `json:"right"`
+}
+
+func (m *moveCommand) validate(c *commandContext) error { return nil }
+
+func (m *moveCommand) handle(c *commandContext) error {
+	return c.update(game.PlayerInputData{Up: m.Up, Down: m.Down, Left: m.Left, Right: m.Right}, game.InputMove)
+}
+
+type aimCommand struct {
+	Angle *float64 `json:"angle"`
+}
+
+func (m *aimCommand) validate(c *commandContext) error {
+	if m.Angle == nil {
+		return rejectf("invalid_angle", "angle is required")
+	}
+	if math.IsNaN(*m.Angle) || math.IsInf(*m.Angle, 0) {
+		return rejectf("invalid_angle", "angle must be finite")
+	}
+	return nil
+}
+
+func (m *aimCommand) handle(c *commandContext) error {
+	return c.update(game.PlayerInputData{Angle: *m.Angle}, game.InputAim)
+}
+
+type shootCommand struct {
+	Active bool `json:"active"` // Кнопка выстрела зажата
+}
+
+func (m *shootCommand) validate(c *commandContext) error { return nil }
+
+func (m *shootCommand) handle(c *commandContext) error {
+	return c.update(game.PlayerInputData{Shoot: m.Active}, game.InputShoot)
+}
+
+type upgradeCommand struct {
+	Stat string `json:"stat"`
+}
+
+func (m *upgradeCommand) validate(c *commandContext) error {
+	if !c.game.HasUpgrade(m.Stat) {
+		return rejectf("unknown_upgrade", "unknown upgrade %q", m.Stat)
+	}
+	return nil
+}
+
+func (m *upgradeCommand) handle(c *commandContext) error {
+	return c.update(game.PlayerInputData{UpgradeStat: m.Stat}, 0)
+}
+
+type chooseClassCommand struct {
+	Class string `json:"class"`
+}
+
+func (m *chooseClassCommand) validate(c *commandContext) error {
+	if !c.game.HasClass(m.Class) {
+		return rejectf("unknown_class", "unknown class %q", m.Class)
+	}
+	err := c.game.CanChooseClass(c.userID, m.Class)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, game.ErrPlayerNotFound):
+		return rejectf("not_in_game", "%v", err)
+	default:
+		return rejectf("class_rejected", "%v", err)
+	}
+}
+
+func (m *chooseClassCommand) handle(c *commandContext) error {
+	return c.update(game.PlayerInputData{Class: m.Class}, 0)
+}
+
+type chatCommand struct {
+	Text string `json:"text"`
+}
+
+func (m *chatCommand) validate(c *commandContext) error {
+	if !utf8.ValidString(m.Text) {
+		return rejectf("invalid_chat", "text must be valid UTF-8")
+	}
+	length := utf8.RuneCountInString(m.Text)
+	if length == 0 || length > maxChatLength {
+		return rejectf("invalid_chat", "text must be 1..%d characters", maxChatLength)
+	}
+	for _, r := range m.Text {
+		if unicode.IsControl(r) {
+			return rejectf("invalid_chat", "text must not contain control characters")
+		}
+	}
+	return nil
+}
+
+func (m *chatCommand) handle(c *commandContext) error {
+	return c.game.Chat(c.userID, m.Text)
+}
+
+type pingCommand struct {
+	Time uint64 `json:"t"` // Время клиента, возвращается в pong
+}
+
+func (m *pingCommand) validate(c *commandContext) error { return nil }
+
+// handle подтверждает снимок из заголовка: клиент, который только
+// пингует, тоже держит базу дельта-сжатия свежей
+func (m *pingCommand) handle(c *commandContext) error {
+	c.game.AckSnapshot(c.userID, c.header.Ack)
+	return c.conn.Send(protocol.TypePong, protocol.Pong{
+		ClientTime: m.Time,
+		ServerTime: time.Now().UnixMilli(),
+	})
+}
+
+// ackCommand только подтверждает снимок из заголовка: клиент без ввода
+// присылает его, чтобы сервер не слал дельты от забытой базы
+type ackCommand struct{}
+
+func (m *ackCommand) validate(c *commandContext) error { return nil }
+
+func (m *ackCommand) handle(c *commandContext) error {
+	c.game.AckSnapshot(c.userID, c.header.Ack)
+	return nil
+}
+
+type respawnCommand struct{}
+
+func (m *respawnCommand) validate(c *commandContext) error {
+	err := c.game.CanRespawn(c.userID)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, game.ErrPlayerNotFound):
+		return rejectf("not_in_game", "%v", err)
+	default:
+		return rejectf("respawn_rejected", "%v", err)
+	}
+}
+
+func (m *respawnCommand) handle(c *commandContext) error {
+	return c.update(game.PlayerInputData{Respawn: true}, 0)
+}
@@ -0,0 +1,155 @@
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gameCore/internal/game"
+	"gameCore/internal/protocol"
+)
+
+// newTestConn возвращает соединение без сокета: отправленные сообщения
+// остаются в очереди, пишущая горутина не запускается
+func newTestConn() *Conn {
+	return &Conn{
+		codec:     protocol.JSONCodec{},
+		version:   protocol.Version,
+		queueSize: 64,
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// sentErrors разбирает ошибки, которые ждут отправки клиенту
+func sentErrors(t *testing.T, c *Conn) []protocol.Error {
+	t.Helper()
+	var errs []protocol.Error
+	for _, msg := range c.queue {
+		var env struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal(msg.data, &env); err != nil {
+			t.Fatal(err)
+		}
+		if env.Type != "error" {
+			continue
+		}
+		var e protocol.Error
+		if err := json.Unmarshal(env.Payload, &e); err != nil {
+			t.Fatal(err)
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+func TestDecodeRoutesByType(t *testing.T) {
+	tests := []struct {
+		name     string
+		raw      string
+		cmd      command
+		code     string
+		reliable bool
+	}{
+		{"движение", `{"type":"move","up":true}`, &moveCommand{Up: true}, "", false},
+		{"прежний формат", `{"up":true,"angle":1}`, &inputCommand{game.PlayerInputData{Up: true, Angle: 1}}, "", false},
+		{"прежний формат с командой", `{"stat":"damage"}`, &inputCommand{game.PlayerInputData{UpgradeStat: "damage"}}, "", true},
+		{"улучшение", `{"type":"upgrade","stat":"damage"}`, &upgradeCommand{Stat: "damage"}, "", true},
+		{"подтверждение снимка", `{"type":"ack","ack":7}`, &ackCommand{}, "", false},
+		{"неизвестный тип", `{"type":"teleport"}`, nil, "unknown_command", false},
+		{"поле не того типа", `{"type":"aim","angle":"north"}`, nil, "invalid_payload", false},
+		{"не JSON-объект", `[1,2]`, nil, "invalid_payload", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &commandContext{}
+			cmd, err := c.decode([]byte(tt.raw))
+
+			var rejected *commandError
+			if tt.code != "" {
+				if !errors.As(err, &rejected) || rejected.Code != tt.code {
+					t.Fatalf("ошибка %v, ожидался код %s", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cmd, tt.cmd) {
+				t.Fatalf("разобрано %#v, ожидалось %#v", cmd, tt.cmd)
+			}
+			if c.reliable(cmd) != tt.reliable {
+				t.Fatalf("лимит команд %v, ожидалось %v", c.reliable(cmd), tt.reliable)
+			}
+		})
+	}
+}
+
+// Отказ в команде приходит клиенту структурированной ошибкой с типом и
+// номером сообщения, а соединение остаётся открытым
+func TestCommandValidationRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID uint
+		raw    string
+		code   string // Пусто - команда принята
+	}{
+		{"прицел без угла", 1, `{"type":"aim"}`, "invalid_angle"},
+		{"прицел", 1, `{"type":"aim","angle":1.5}`, ""},
+		{"пустой чат", 1, `{"type":"chat","text":""}`, "invalid_chat"},
+		{"длинный чат", 1, `{"type":"chat","text":"` + strings.Repeat("я", maxChatLength+1) + `"}`, "invalid_chat"},
+		{"управляющие символы в чате", 1, `{"type":"chat","text":"hi\u0007"}`, "invalid_chat"},
+		{"неизвестное улучшение", 1, `{"type":"upgrade","stat":"jump"}`, "unknown_upgrade"},
+		{"улучшение", 1, `{"type":"upgrade","stat":"damage"}`, ""},
+		{"неизвестный класс", 1, `{"type":"choose_class","class":"wizard"}`, "unknown_class"},
+		{"класс не открыт по уровню", 1, `{"type":"choose_class","class":"twin"}`, "class_rejected"},
+		{"класс из чужой ветки", 1, `{"type":"choose_class","class":"triple_shot"}`, "class_rejected"},
+		{"текущий класс", 1, `{"type":"choose_class","class":"basic"}`, ""},
+		{"класс вне игры", 2, `{"type":"choose_class","class":"twin"}`, "not_in_game"},
+		{"возрождение живого", 1, `{"type":"respawn_request"}`, "respawn_rejected"},
+		{"возрождение вне игры", 2, `{"type":"respawn_request"}`, "not_in_game"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := game.NewGame(game.WithSeed(1))
+			if err := g.AddPlayer(1, nil); err != nil {
+				t.Fatal(err)
+			}
+			conn := newTestConn()
+			c := &commandContext{game: g, conn: conn, userID: tt.userID}
+
+			raw := strings.Replace(tt.raw, "{", `{"seq":9,`, 1)
+			cmd, err := c.decode([]byte(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = c.run(cmd)
+
+			var rejected *commandError
+			if tt.code == "" {
+				if err != nil {
+					t.Fatalf("команда отклонена: %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &rejected) {
+				t.Fatalf("ошибка %v не оформлена как отказ в команде", err)
+			}
+			c.reject(rejected)
+
+			sent := sentErrors(t, conn)
+			if len(sent) != 1 {
+				t.Fatalf("клиенту отправлено %d ошибок", len(sent))
+			}
+			want := protocol.Error{Code: tt.code, Message: rejected.Message, Command: c.header.Type, Seq: 9}
+			if sent[0] != want {
+				t.Fatalf("отправлено %+v, ожидалось %+v", sent[0], want)
+			}
+		})
+	}
+}
@@ -0,0 +1,403 @@
+package network
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"gameCore/internal/config"
+	"gameCore/internal/game"
+	"gameCore/internal/protocol"
+
+	"github.com/gorilla/websocket"
+)
+
+// DropPolicy определяет, что делать с ненадёжным сообщением при полной очереди
+type DropPolicy string
+
+const (
+	DropOldest DropPolicy = "drop_oldest" // Выбросить самый старый снимок из очереди
+	DropNewest DropPolicy = "drop_newest" // Не ставить в очередь новый снимок
+)
+
+type outboundMessage struct {
+	data     []byte
+	binary   bool
+	reliable bool
+}
+
+// Conn оборачивает websocket.Conn единственной пишущей горутиной с
+// ограниченной очередью. gorilla/websocket не допускает параллельной записи,
+// а игровой цикл не должен ждать медленного клиента
+type Conn struct {
+	ws           *websocket.Conn
+	codec        protocol.Codec
+	version      int // Согласованная версия протокола
+	writeTimeout time.Duration
+	readTimeout  time.Duration // Сколько ждать следующего кадра от клиента
+	pingInterval time.Duration
+	pongTimeout  time.Duration
+	queueSize    int
+	queueTimeout time.Duration
+	policy       DropPolicy
+
+	mu        sync.Mutex
+	queue     []outboundMessage
+	fullSince time.Time // Когда очередь стала полной, нулевое значение - не полна
+	closed    bool
+	dropped   int
+	seq       uint32 // Номер последнего отправленного конверта
+
+	pingSeq    uint64        // Номер последнего отправленного пинга
+	pingSentAt time.Time     // Когда отправлен последний пинг
+	awaitPong  time.Time     // Первый пинг без ответа, нулевое значение - ответ получен
+	rtt        time.Duration // Задержка по последней паре пинг/понг
+
+	closeCode   int    // Код кадра закрытия, 0 - обычное закрытие
+	closeReason string // Причина закрытия для клиента
+
+	notify     chan struct{}
+	done       chan struct{}
+	writerDone chan struct{} // Закрывается, когда пишущая горутина отпустила сокет
+	closeOnce  sync.Once
+}
+
+var _ game.Connection = (*Conn)(nil)
+
+func NewConn(ws *websocket.Conn, cfg config.WebSocketConfig, codec protocol.Codec, version int) *Conn {
+	c := &Conn{
+		ws:           ws,
+		codec:        codec,
+		version:      version,
+		writeTimeout: cfg.WriteTimeout,
+		readTimeout:  cfg.ReadTimeout,
+		pingInterval: cfg.PingInterval,
+		pongTimeout:  cfg.PongTimeout,
+		queueSize:    cfg.SendQueueSize,
+		queueTimeout: cfg.SendQueueTimeout,
+		policy:       DropPolicy(cfg.DropPolicy),
+		queue:        make([]outboundMessage, 0, cfg.SendQueueSize),
+		notify:       make(chan struct{}, 1),
+		done:         make(chan struct{}),
+		writerDone:   make(chan struct{}),
+	}
+
+	// Сообщение больше лимита рвёт соединение с кодом 1009
+	if cfg.MaxMessageSize > 0 {
+		ws.SetReadLimit(int64(cfg.MaxMessageSize))
+	}
+	c.extendReadDeadline()
+	ws.SetPongHandler(c.handlePong)
+
+	go c.writeLoop()
+	return c
+}
+
+// ReadJSON читает следующее сообщение клиента. Каждое сообщение, как и
+// понг, продлевает срок чтения: молчащее соединение считается мёртвым
+func (c *Conn) ReadJSON(v interface{}) error {
+	if err := c.ws.ReadJSON(v); err != nil {
+		return err
+	}
+	c.extendReadDeadline()
+	return nil
+}
+
+func (c *Conn) extendReadDeadline() {
+	if c.readTimeout > 0 {
+		c.ws.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+}
+
+// handlePong вызывается из читающей горутины
+func (c *Conn) handlePong(data string) error {
+	now := time.Now()
+	c.mu.Lock()
+	if data == strconv.FormatUint(c.pingSeq, 10) {
+		c.rtt = now.Sub(c.pingSentAt)
+	}
+	c.awaitPong = time.Time{}
+	c.mu.Unlock()
+
+	c.extendReadDeadline()
+	return nil
+}
+
+// ping отправляет очередной пинг. Возвращает false, если клиент не ответил
+// на пинг за pongTimeout
+func (c *Conn) ping() bool {
+	now := time.Now()
+	c.mu.Lock()
+	if !c.awaitPong.IsZero() && now.Sub(c.awaitPong) > c.pongTimeout {
+		c.mu.Unlock()
+		log.Printf("Клиент не отвечает на пинг дольше %v, отключаем", c.pongTimeout)
+		return false
+	}
+	if c.awaitPong.IsZero() {
+		c.awaitPong = now
+	}
+	c.pingSeq++
+	c.pingSentAt = now
+	payload := strconv.FormatUint(c.pingSeq, 10)
+	c.mu.Unlock()
+
+	return c.ws.WriteControl(websocket.PingMessage, []byte(payload), now.Add(c.writeTimeout)) == nil
+}
+
+// RTT возвращает задержку до клиента по последнему понгу
+func (c *Conn) RTT() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rtt
+}
+
+// Send кодирует конверт сразу, чтобы очередь не ссылалась на изменяемое
+// состояние игры, и ставит его в очередь без блокировки
+func (c *Conn) Send(msgType protocol.MessageType, payload interface{}) error {
+	c.mu.Lock()
+
+	if c.closed {
+		c.mu.Unlock()
+		return game.ErrConnectionClosed
+	}
+
+	// Номер присваивается под мьютексом, чтобы порядок в очереди совпадал с seq
+	c.seq++
+	data, err := c.codec.Encode(protocol.Envelope{
+		Type:    msgType,
+		Seq:     c.seq,
+		Payload: payload,
+		Version: c.version,
+	})
+	if err != nil {
+		c.mu.Unlock()
+		return err
+	}
+
+	return c.enqueueLocked(outboundMessage{
+		data:     data,
+		binary:   c.codec.Binary(),
+		reliable: msgType.Reliable(),
+	})
+}
+
+// enqueueLocked вызывается под c.mu и отпускает его
+func (c *Conn) enqueueLocked(msg outboundMessage) error {
+	now := time.Now()
+	if len(c.queue) >= c.queueSize {
+		if !c.fullSince.IsZero() && now.Sub(c.fullSince) > c.queueTimeout {
+			c.mu.Unlock()
+			log.Printf("Очередь отправки переполнена дольше %v, отключаем клиента", c.queueTimeout)
+			c.abort()
+			return game.ErrConnectionClosed
+		}
+
+		if !c.makeRoom(msg) {
+			c.dropped++
+			c.mu.Unlock()
+			return nil
+		}
+	}
+
+	c.queue = append(c.queue, msg)
+
+	// Надёжные сообщения не выбрасываются, но и расти бесконечно очередь не может
+	if len(c.queue) >= 2*c.queueSize {
+		c.mu.Unlock()
+		log.Printf("Очередь надёжных сообщений переполнена, отключаем клиента")
+		c.abort()
+		return game.ErrConnectionClosed
+	}
+
+	if len(c.queue) >= c.queueSize {
+		if c.fullSince.IsZero() {
+			c.fullSince = now
+		}
+	} else {
+		c.fullSince = time.Time{}
+	}
+	c.mu.Unlock()
+
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// makeRoom освобождает место в полной очереди согласно политике.
+// Возвращает false, если новое сообщение нужно отбросить
+func (c *Conn) makeRoom(msg outboundMessage) bool {
+	if c.policy == DropNewest && !msg.reliable {
+		return false
+	}
+
+	for i, queued := range c.queue {
+		if !queued.reliable {
+			c.queue = append(c.queue[:i], c.queue[i+1:]...)
+			c.dropped++
+			return true
+		}
+	}
+
+	// В очереди только надёжные сообщения: ненадёжное отбрасываем,
+	// надёжное всё равно добавляем
+	return msg.reliable
+}
+
+func (c *Conn) pop() (outboundMessage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.queue) == 0 {
+		return outboundMessage{}, false
+	}
+	msg := c.queue[0]
+	c.queue[0] = outboundMessage{}
+	c.queue = c.queue[1:]
+	if len(c.queue) < c.queueSize {
+		c.fullSince = time.Time{}
+	}
+	return msg, true
+}
+
+func (c *Conn) writeLoop() {
+	defer close(c.writerDone)
+	defer c.ws.Close()
+
+	// Пинги идут из пишущей горутины, чтобы не заводить ещё одну на соединение
+	var pings <-chan time.Time
+	if c.pingInterval > 0 {
+		ticker := time.NewTicker(c.pingInterval)
+		defer ticker.Stop()
+		pings = ticker.C
+	}
+
+	for {
+		select {
+		case <-pings:
+			if !c.ping() {
+				c.abort()
+				return
+			}
+			continue
+		case <-c.notify:
+		case <-c.done:
+			// Дописываем то, что уже в очереди, и прощаемся с клиентом
+			if c.flush() {
+				c.writeClose()
+			}
+			return
+		}
+
+		if !c.flush() {
+			c.abort()
+			return
+		}
+	}
+}
+
+// flush отправляет все сообщения из очереди. Возвращает false при ошибке записи
+func (c *Conn) flush() bool {
+	for {
+		msg, ok := c.pop()
+		if !ok {
+			return true
+		}
+
+		if err := c.ws.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return false
+		}
+		frameType := websocket.TextMessage
+		if msg.binary {
+			frameType = websocket.BinaryMessage
+		}
+		if err := c.ws.WriteMessage(frameType, msg.data); err != nil {
+			log.Printf("Ошибка записи в WebSocket: %v", err)
+			return false
+		}
+	}
+}
+
+// writeClose отправляет кадр закрытия с кодом и причиной
+func (c *Conn) writeClose() {
+	c.mu.Lock()
+	code, reason := c.closeCode, c.closeReason
+	c.mu.Unlock()
+	if code == 0 {
+		code = websocket.CloseNormalClosure
+	}
+
+	// Клиент мог уже уйти сам, поэтому ошибку записи не считаем сбоем
+	deadline := time.Now().Add(c.writeTimeout)
+	_ = c.ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), deadline)
+}
+
+// SetCloseReason задаёт код и причину, которые клиент получит при закрытии.
+// Само соединение не закрывается
+func (c *Conn) SetCloseReason(code int, reason string) {
+	c.mu.Lock()
+	c.closeCode, c.closeReason = code, reason
+	c.mu.Unlock()
+}
+
+// CloseWithReason закрывает соединение, сообщая клиенту код и причину
+func (c *Conn) CloseWithReason(code int, reason string) {
+	c.SetCloseReason(code, reason)
+	c.Close()
+}
+
+// Wait ждёт, пока очередь будет дописана и сокет закрыт, или отмены ctx
+func (c *Conn) Wait(ctx context.Context) error {
+	select {
+	case <-c.writerDone:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close дописывает очередь и закрывает соединение
+func (c *Conn) Close() {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.closed = true
+		c.mu.Unlock()
+		close(c.done)
+	})
+}
+
+// abort немедленно рвёт соединение, не дожидаясь отправки очереди
+func (c *Conn) abort() {
+	c.mu.Lock()
+	c.closed = true
+	c.queue = nil
+	c.mu.Unlock()
+
+	c.Close()
+	c.ws.Close()
+}
+
+// Version возвращает согласованную версию протокола
+func (c *Conn) Version() int {
+	return c.version
+}
+
+func (c *Conn) welcome(playerID uint, room string) protocol.Welcome {
+	return protocol.Welcome{
+		ProtocolVersion: c.version,
+		MinVersion:      protocol.MinVersion,
+		Encoding:        c.codec.Name(),
+		PlayerID:        playerID,
+		Room:            room,
+	}
+}
+
+// Dropped возвращает количество отброшенных снимков
+func (c *Conn) Dropped() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped
+}
@@ -0,0 +1,20 @@
+package network
+
+import (
+	"errors"
+	"log"
+
+	"gameCore/internal/game"
+	"gameCore/internal/protocol"
+)
+
+// forwardEvents - подписчик событий комнат. Каждый клиент получает свои
+// события тика одним надёжным сообщением: при переполнении очереди
+// вытесняются снимки, а не события
+func (s *WebSocketServer) forwardEvents(g *game.Game, events []game.Event) {
+	for conn, batch := range g.RouteEvents(events) {
+		if err := conn.Send(protocol.TypeEvents, batch); err != nil && !errors.Is(err, game.ErrConnectionClosed) {
+			log.Printf("Ошибка отправки событий комнаты %s: %v", g.ID, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package network
+
+import (
+	"sync/atomic"
+	"time"
+
+	"gameCore/internal/config"
+)
+
+// floodWindow - окно, за которое считаются нарушения. Пороги предупреждения
+// и отключения задают число нарушений за окно, а не за всё время соединения
+const floodWindow = 10 * time.Second
+
+// floodAction - реакция на сообщение сверх лимита
+type floodAction int
+
+const (
+	floodAllow floodAction = iota // Сообщение в пределах лимита
+	floodDrop                     // Сообщение отброшено
+	floodWarn                     // Отброшено, клиент предупреждён
+	floodKick                     // Клиент отключается
+)
+
+// tokenBucket пропускает rate сообщений в секунду с запасом burst
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) tokenBucket {
+	return tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+func (b *tokenBucket) allow(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// floodGuard ограничивает входящие сообщения одного соединения. Движение
+// и команды считаются отдельно: частое движение не должно отнимать место
+// у редких команд. Используется только читающей горутиной соединения
+type floodGuard struct {
+	moves     tokenBucket
+	commands  tokenBucket
+	warnAfter int
+	kickAfter int
+	window    strikeWindow
+	strikes   int // Нарушений за последние floodWindow
+	warned    bool
+}
+
+func newFloodGuard(cfg config.WebSocketConfig, now time.Time) *floodGuard {
+	return &floodGuard{
+		moves:     newTokenBucket(cfg.InputRate, cfg.InputBurst, now),
+		commands:  newTokenBucket(cfg.CommandRate, cfg.CommandBurst, now),
+		warnAfter: cfg.FloodWarnAfter,
+		kickAfter: cfg.FloodKickAfter,
+		window:    strikeWindow{start: now},
+	}
+}
+
+// strikeWindow - скользящее окно нарушений: счётчик прошлого окна входит
+// в сумму с весом, убывающим по мере хода текущего. Ровный поток чуть выше
+// лимита держит сумму постоянной, а не копит её до отключения
+type strikeWindow struct {
+	start    time.Time // Начало текущего окна
+	current  int
+	previous int
+}
+
+func (w *strikeWindow) add(now time.Time) int {
+	elapsed := now.Sub(w.start)
+	switch {
+	case elapsed >= 2*floodWindow:
+		w.start, w.previous, w.current = now, 0, 0
+	case elapsed >= floodWindow:
+		w.start, w.previous, w.current = w.start.Add(floodWindow), w.current, 0
+	}
+	w.current++
+
+	weight := 1 - float64(now.Sub(w.start))/float64(floodWindow)
+	return w.current + int(float64(w.previous)*weight)
+}
+
+// check решает, что делать с очередным сообщением
+func (f *floodGuard) check(command bool, now time.Time) floodAction {
+	bucket := &f.moves
+	if command {
+		bucket = &f.commands
+	}
+	if bucket.allow(now) {
+		return floodAllow
+	}
+
+	f.strikes = f.window.add(now)
+	if f.strikes < f.warnAfter {
+		// Клиент успокоился - при новом всплеске предупреждаем снова
+		f.warned = false
+	}
+
+	switch {
+	case f.strikes >= f.kickAfter:
+		return floodKick
+	case f.strikes >= f.warnAfter && !f.warned:
+		f.warned = true
+		return floodWarn
+	default:
+		return floodDrop
+	}
+}
+
+// strike засчитывает нарушение, не связанное с частотой, например
+// переполненную очередь команд комнаты. Сообщение уже обработано, поэтому
+// без отключения оно не считается отброшенным
+func (f *floodGuard) strike(now time.Time) floodAction {
+	f.strikes = f.window.add(now)
+	if f.strikes >= f.kickAfter {
+		return floodKick
+	}
+	return floodAllow
+}
+
+// FloodStats - счётчики защиты от флуда по всему серверу
+type FloodStats struct {
+	Dropped  int64 `json:"dropped"`  // Отброшено сообщений сверх лимита
+	Rejected int64 `json:"rejected"` // Сообщений, которые комната не приняла
+	Warned   int64 `json:"warned"`   // Выдано предупреждений
+	Kicked   int64 `json:"kicked"`   // Отключено клиентов
+}
+
+type floodCounters struct {
+	dropped  atomic.Int64
+	rejected atomic.Int64
+	warned   atomic.Int64
+	kicked   atomic.Int64
+}
+
+func (c *floodCounters) snapshot() FloodStats {
+	return FloodStats{
+		Dropped:  c.dropped.Load(),
+		Rejected: c.rejected.Load(),
+		Warned:   c.warned.Load(),
+		Kicked:   c.kicked.Load(),
+	}
+}
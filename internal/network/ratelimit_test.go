@@ -0,0 +1,142 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"gameCore/internal/config"
+)
+
+var testFloodConfig = config.WebSocketConfig{
+	InputRate:      300,
+	InputBurst:     150,
+	CommandRate:    10,
+	CommandBurst:   20,
+	FloodWarnAfter: 100,
+	FloodKickAfter: 1000,
+}
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := newTokenBucket(10, 3, start)
+
+	for i := 0; i < 3; i++ {
+		if !b.allow(start) {
+			t.Fatalf("сообщение %d из запаса отклонено", i+1)
+		}
+	}
+	if b.allow(start) {
+		t.Fatal("сообщение сверх запаса пропущено")
+	}
+	if !b.allow(start.Add(100 * time.Millisecond)) {
+		t.Fatal("за 100 мс при 10 в секунду должен накопиться токен")
+	}
+	if b.allow(start.Add(100 * time.Millisecond)) {
+		t.Fatal("накопленный токен израсходован дважды")
+	}
+	// Запас не растёт выше burst, сколько бы клиент ни молчал
+	later := start.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		b.allow(later)
+	}
+	if b.allow(later) {
+		t.Fatal("запас вырос выше burst")
+	}
+}
+
+// Нарушения прошлого окна входят в сумму с весом, убывающим по ходу текущего
+func TestStrikeWindow(t *testing.T) {
+	start := time.Unix(0, 0)
+	w := strikeWindow{start: start}
+
+	steps := []struct {
+		at   time.Duration
+		want int
+	}{
+		{0, 1},
+		{0, 2},
+		{floodWindow, 3},                   // Новое окно, прошлое входит целиком
+		{floodWindow + floodWindow/2, 3},   // Прошлое окно наполовину: 2 + 2*0.5
+		{2*floodWindow + floodWindow/2, 2}, // Сдвиг на одно окно: 1 + 2*0.5
+		{5 * floodWindow, 1},               // Долгое молчание обнуляет счёт
+	}
+	for i, step := range steps {
+		if got := w.add(start.Add(step.at)); got != step.want {
+			t.Fatalf("шаг %d (%v): нарушений %d, ожидалось %d", i+1, step.at, got, step.want)
+		}
+	}
+}
+
+// flood шлёт rate сообщений движения в секунду в течение d и возвращает
+// первое решение об отключении и число предупреждений
+func flood(guard *floodGuard, start time.Time, rate float64, d time.Duration) (kickedAt time.Duration, warnings int) {
+	interval := time.Duration(float64(time.Second) / rate)
+	for at := time.Duration(0); at < d; at += interval {
+		switch guard.check(false, start.Add(at)) {
+		case floodKick:
+			return at, warnings
+		case floodWarn:
+			warnings++
+		}
+	}
+	return -1, warnings
+}
+
+func TestFloodGuard(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		duration time.Duration
+		kick     bool
+	}{
+		{"в пределах лимита", 290, 10 * time.Minute, false},
+		{"чуть выше лимита долго", 350, 10 * time.Minute, false},
+		{"флуд", 600, time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Unix(0, 0)
+			kickedAt, _ := flood(newFloodGuard(testFloodConfig, start), start, tt.rate, tt.duration)
+			if kicked := kickedAt >= 0; kicked != tt.kick {
+				t.Fatalf("отключение %v (на %v), ожидалось %v", kicked, kickedAt, tt.kick)
+			}
+		})
+	}
+}
+
+// Предупреждение приходит один раз за всплеск и снова после затишья
+func TestFloodGuardWarnsOncePerBurst(t *testing.T) {
+	start := time.Unix(0, 0)
+	guard := newFloodGuard(testFloodConfig, start)
+
+	if _, warnings := flood(guard, start, 400, 5*time.Second); warnings != 1 {
+		t.Fatalf("за всплеск предупреждений %d, ожидалось 1", warnings)
+	}
+	quiet := start.Add(time.Minute)
+	if _, warnings := flood(guard, quiet, 400, 5*time.Second); warnings != 1 {
+		t.Fatalf("после затишья предупреждений %d, ожидалось 1", warnings)
+	}
+}
+
+// Команды считаются отдельно от движения, а отказ комнаты не отбрасывает
+// сообщение, пока нарушений не наберётся на отключение
+func TestFloodGuardCommandsAndStrikes(t *testing.T) {
+	start := time.Unix(0, 0)
+	guard := newFloodGuard(testFloodConfig, start)
+	for i := 0; i < testFloodConfig.InputBurst; i++ {
+		guard.check(false, start)
+	}
+	if guard.check(true, start) != floodAllow {
+		t.Fatal("исчерпанный лимит движения отклонил команду")
+	}
+
+	for i := 1; i < testFloodConfig.FloodKickAfter; i++ {
+		if action := guard.strike(start); action != floodAllow {
+			t.Fatalf("нарушение %d: %v, ожидалось floodAllow", i, action)
+		}
+	}
+	if guard.strike(start) != floodKick {
+		t.Fatal("порог отключения по нарушениям не сработал")
+	}
+}
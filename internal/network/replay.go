@@ -0,0 +1,76 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"gameCore/internal/game"
+	"gameCore/internal/protocol"
+)
+
+// Допустимый множитель скорости повтора
+const (
+	minReplaySpeed = 0.25
+	maxReplaySpeed = 16.0
+)
+
+// HandleReplayWS проигрывает запись сессии sessionID так, будто матч идёт
+// вживую. Скорость задаётся параметром ?speed=<множитель>
+func (s *WebSocketServer) HandleReplayWS(w http.ResponseWriter, r *http.Request, sessionID uint) {
+	conn, ok := s.accept(w, r)
+	if !ok {
+		return
+	}
+	defer conn.Close()
+
+	replay, err := s.Rooms.OpenReplay(sessionID)
+	if err != nil {
+		log.Printf("Open replay %d error: %v", sessionID, err)
+		code, message := "replay_failed", "Failed to open replay"
+		if errors.Is(err, game.ErrReplayNotFound) || errors.Is(err, game.ErrReplayDisabled) {
+			code, message = "replay_not_found", err.Error()
+		}
+		conn.Send(protocol.TypeError, protocol.Error{Code: code, Message: message})
+		return
+	}
+
+	speed := 1.0
+	if param := r.URL.Query().Get("speed"); param != "" {
+		v, err := strconv.ParseFloat(param, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			conn.Send(protocol.TypeError, protocol.Error{Code: "invalid_speed", Message: "speed must be a finite number"})
+			return
+		}
+		speed = math.Max(minReplaySpeed, math.Min(v, maxReplaySpeed))
+	}
+
+	welcome := conn.welcome(0, replay.RoomID())
+	welcome.Spectator = true
+	conn.Send(protocol.TypeWelcome, welcome)
+
+	// Читатель нужен, чтобы заметить отключение и принять подтверждения снимков
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	acks := make(chan uint32, 16)
+	go func() {
+		defer cancel()
+		for {
+			var input game.PlayerInputData
+			if err := conn.ReadJSON(&input); err != nil {
+				return
+			}
+			select {
+			case acks <- input.Ack:
+			default:
+			}
+		}
+	}()
+
+	if err := replay.Stream(ctx, conn, acks, speed); err != nil && !errors.Is(err, game.ErrConnectionClosed) {
+		log.Printf("Replay %d stream error: %v", sessionID, err)
+	}
+}
@@ -0,0 +1,69 @@
+package network
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"gameCore/internal/game"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoomHandler struct {
+	rooms *game.RoomManager
+}
+
+func NewRoomHandler(rooms *game.RoomManager) *RoomHandler {
+	return &RoomHandler{rooms: rooms}
+}
+
+type createRoomRequest struct {
+	ID string `json:"id"`
+}
+
+func (h *RoomHandler) ListRoomsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rooms": h.rooms.Rooms()})
+}
+
+func (h *RoomHandler) CreateRoomHandler(c *gin.Context) {
+	var req createRoomRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	g, err := h.rooms.CreateRoom(c.Request.Context(), req.ID, c.GetUint("userID"))
+	switch {
+	case errors.Is(err, game.ErrRoomExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "room already exists"})
+	case errors.Is(err, game.ErrShuttingDown):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case err != nil:
+		log.Printf("Create room error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create room"})
+	default:
+		c.JSON(http.StatusCreated, gin.H{
+			"id":          g.ID,
+			"session_id":  g.SessionID,
+			"max_players": g.MaxPlayers,
+		})
+	}
+}
+
+func (h *RoomHandler) DeleteRoomHandler(c *gin.Context) {
+	err := h.rooms.DestroyRoom(c.Request.Context(), c.Param("id"), c.GetUint("userID"))
+	switch {
+	case errors.Is(err, game.ErrRoomNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "room not found"})
+	case errors.Is(err, game.ErrNotRoomOwner):
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the room creator can delete it"})
+	case err != nil:
+		log.Printf("Delete room error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete room"})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
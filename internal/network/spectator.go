@@ -0,0 +1,54 @@
+package network
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"gameCore/internal/game"
+	"gameCore/internal/protocol"
+)
+
+// handleSpectator подключает зрителя к комнате ?room=<id>. Камера следует
+// за игроком ?follow=<id>, без параметра зритель видит весь мир
+func (s *WebSocketServer) handleSpectator(conn *Conn, r *http.Request, userID uint) {
+	query := r.URL.Query()
+	var follow uint
+	if param := query.Get("follow"); param != "" {
+		if v, err := strconv.ParseUint(param, 10, 64); err == nil {
+			follow = uint(v)
+		}
+	}
+
+	g, err := s.Rooms.Spectate(query.Get("room"), userID, conn, follow)
+	if err != nil {
+		log.Printf("Add spectator error: %v", err)
+		message := "Failed to spectate game"
+		if errors.Is(err, game.ErrRoomNotFound) || errors.Is(err, game.ErrSpectatorExists) ||
+			errors.Is(err, game.ErrSpectatorInGame) {
+			message = err.Error()
+		}
+		conn.Send(protocol.TypeError, protocol.Error{
+			Code:    "join_failed",
+			Message: message,
+		})
+		return
+	}
+	defer g.RemoveSpectator(userID)
+
+	welcome := conn.welcome(userID, g.ID)
+	welcome.Spectator = true
+	conn.Send(protocol.TypeWelcome, welcome)
+
+	// Зритель не может управлять игрой: из сообщений берутся только
+	// подтверждение снимка и смена камеры
+	for {
+		var input game.SpectatorInputData
+		if err := conn.ReadJSON(&input); err != nil {
+			logReadError("Spectator", userID, err)
+			return
+		}
+		g.UpdateSpectator(userID, input)
+	}
+}
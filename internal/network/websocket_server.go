@@ -1,35 +1,94 @@
 package network
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"gameCore/internal/config"
 	"gameCore/internal/game"
+	"gameCore/internal/protocol"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
+// shutdownReason - причина закрытия соединений при остановке сервера
+const shutdownReason = "server shutting down"
+
 type WebSocketServer struct {
-	Game     *game.Game
+	Rooms    *game.RoomManager
 	Config   config.WebSocketConfig
 	upgrader websocket.Upgrader
+
+	mu       sync.Mutex
+	conns    map[*Conn]struct{} // Открытые соединения для остановки сервера
+	draining bool               // Сервер останавливается и не принимает подключений
+	flood    floodCounters
 }
 
-func NewWebSocketServer(gameInstance *game.Game, wsConfig config.WebSocketConfig) *WebSocketServer {
+func NewWebSocketServer(rooms *game.RoomManager, wsConfig config.WebSocketConfig) *WebSocketServer {
 	if wsConfig.ReadBufferSize == 0 {
 		wsConfig.ReadBufferSize = 4096
 	}
 	if wsConfig.WriteBufferSize == 0 {
 		wsConfig.WriteBufferSize = 4096
 	}
+	if wsConfig.PingInterval == 0 {
+		wsConfig.PingInterval = 5 * time.Second
+	}
 	if wsConfig.PongTimeout == 0 {
-		wsConfig.PongTimeout = 60 * time.Second
+		wsConfig.PongTimeout = 15 * time.Second
+	}
+	if wsConfig.ReadTimeout == 0 {
+		// Между кадрами клиента проходит не больше интервала пинга и ожидания понга
+		wsConfig.ReadTimeout = wsConfig.PingInterval + wsConfig.PongTimeout
+	}
+	if wsConfig.MaxMessageSize == 0 {
+		wsConfig.MaxMessageSize = 4096
+	}
+	if wsConfig.WriteTimeout == 0 {
+		wsConfig.WriteTimeout = 10 * time.Second
+	}
+	if wsConfig.SendQueueSize == 0 {
+		wsConfig.SendQueueSize = 64
+	}
+	if wsConfig.SendQueueTimeout == 0 {
+		wsConfig.SendQueueTimeout = 5 * time.Second
+	}
+	if wsConfig.DropPolicy == "" {
+		wsConfig.DropPolicy = string(DropOldest)
+	}
+	if wsConfig.InputRate == 0 {
+		// Клиент шлёт прицел на каждый кадр (до 240 Гц) и автоповтор клавиш.
+		// Движение всё равно объединяется до одного сообщения за тик
+		wsConfig.InputRate = 300
+	}
+	if wsConfig.InputBurst == 0 {
+		wsConfig.InputBurst = 150
+	}
+	if wsConfig.CommandRate == 0 {
+		wsConfig.CommandRate = 10
+	}
+	if wsConfig.CommandBurst == 0 {
+		wsConfig.CommandBurst = 20
+	}
+	if wsConfig.FloodWarnAfter == 0 {
+		wsConfig.FloodWarnAfter = 100
+	}
+	if wsConfig.FloodKickAfter == 0 {
+		wsConfig.FloodKickAfter = 1000
 	}
 
-	return &WebSocketServer{
-		Game:   gameInstance,
+	s := &WebSocketServer{
+		Rooms:  rooms,
 		Config: wsConfig,
+		conns:  make(map[*Conn]struct{}),
 		upgrader: websocket.Upgrader{
 			ReadBufferSize:   wsConfig.ReadBufferSize,
 			WriteBufferSize:  wsConfig.WriteBufferSize,
@@ -37,51 +96,261 @@ func NewWebSocketServer(gameInstance *game.Game, wsConfig config.WebSocketConfig
 			HandshakeTimeout: 10 * time.Second,
 		},
 	}
+	// Убийства, урон и повышения уровня клиенты получают отдельными событиями
+	rooms.SubscribeEvents(s.forwardEvents)
+	return s
 }
 
 func (s *WebSocketServer) HandleWS(w http.ResponseWriter, r *http.Request, userID uint) {
-	conn, err := s.upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+	conn, ok := s.accept(w, r)
+	if !ok {
 		return
 	}
 	defer conn.Close()
 
+	// ?spectate=1 подключает только для просмотра, без места игрока
+	if r.URL.Query().Get("spectate") == "1" {
+		s.handleSpectator(conn, r, userID)
+		return
+	}
+
+	// Комната выбирается параметром ?room=<id>, иначе матчмейкингом.
+	// ?resume=<token> перехватывает сессию, которую сервер ещё считает живой
+	roomID := r.URL.Query().Get("room")
+	resumeToken := r.URL.Query().Get("resume")
+
 	// Добавляем игрока с аутентифицированным ID
-	err = s.Game.AddPlayer(userID, conn) // Используем userID из middleware
+	g, err := s.Rooms.JoinRoom(r.Context(), roomID, userID, conn, resumeToken) // Используем userID из middleware
 	if err != nil {
 		log.Printf("Add player error: %v", err)
-		conn.WriteJSON(map[string]interface{}{
-			"error": "Failed to join game",
+		message := "Failed to join game"
+		if errors.Is(err, game.ErrRoomNotFound) || errors.Is(err, game.ErrRoomFull) ||
+			errors.Is(err, game.ErrPlayerExists) || errors.Is(err, game.ErrInvalidResumeToken) ||
+			errors.Is(err, game.ErrShuttingDown) || errors.Is(err, game.ErrKickCooldown) ||
+			errors.Is(err, game.ErrPlayerSpectates) {
+			message = err.Error()
+		}
+		conn.Send(protocol.TypeError, protocol.Error{
+			Code:    "join_failed",
+			Message: message,
 		})
 		return
 	}
 
 	// Уведомление об успешном подключении
-	conn.WriteJSON(map[string]interface{}{
-		"yourId": userID,
-		"status": "connected",
-	})
+	welcome := conn.welcome(userID, g.ID)
+	welcome.ResumeToken = g.ResumeToken(userID)
+	conn.Send(protocol.TypeWelcome, welcome)
+	// Каталог улучшений нужен клиенту до первого очка навыка
+	conn.Send(protocol.TypeUpgradeCatalogue, g.UpgradeCatalogue())
 
 	// Обработчик входящих сообщений
-	go s.handleMessages(conn, userID)
+	s.handleMessages(conn, g, userID)
+}
+
+// accept поднимает WebSocket и согласует протокол. Версия и кодировка
+// задаются параметрами ?protocol=<n>&encoding=json|binary
+func (s *WebSocketServer) accept(w http.ResponseWriter, r *http.Request) (*Conn, bool) {
+	s.mu.Lock()
+	draining := s.draining
+	s.mu.Unlock()
+	if draining {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return nil, false
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade error: %v", err)
+		return nil, false
+	}
+
+	query := r.URL.Query()
+	version, codec, err := negotiate(query.Get("protocol"), query.Get("encoding"))
+	if err != nil {
+		// Ошибку согласования отдаём в JSON: договориться о другом не вышло
+		conn := NewConn(ws, s.Config, protocol.JSONCodec{}, protocol.Version)
+		conn.Send(protocol.TypeError, protocol.Error{
+			Code:    "unsupported_protocol",
+			Message: err.Error(),
+		})
+		conn.Close()
+		return nil, false
+	}
+
+	// Вся запись идёт через очередь соединения и его пишущую горутину
+	conn := NewConn(ws, s.Config, codec, version)
+	if !s.track(conn) {
+		conn.CloseWithReason(websocket.CloseGoingAway, shutdownReason)
+		return nil, false
+	}
+	return conn, true
 }
 
-func (s *WebSocketServer) handleMessages(conn *websocket.Conn, userID uint) {
-	defer s.Game.RemovePlayer(userID)
+// track запоминает соединение до его закрытия. Возвращает false, если
+// сервер уже останавливается
+func (s *WebSocketServer) track(conn *Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
+	if s.draining {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	go func() {
+		<-conn.writerDone
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	return true
+}
+
+// Shutdown перестаёт принимать подключения, останавливает комнаты с
+// сохранением итогов и закрывает соединения с причиной shutdownReason.
+// Ждёт, пока клиенты получат оставшиеся сообщения, но не дольше ctx
+func (s *WebSocketServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	conns := make([]*Conn, 0, len(s.conns))
+	for conn := range s.conns {
+		conns = append(conns, conn)
+	}
+	s.mu.Unlock()
+
+	// Комнаты закрывают соединения игроков сами, после итоговых сообщений
+	for _, conn := range conns {
+		conn.SetCloseReason(websocket.CloseGoingAway, shutdownReason)
+	}
+	err := s.Rooms.Shutdown(ctx)
+
+	flood := s.FloodStats()
+	log.Printf("Защита от флуда: отброшено %d сообщений, не принято %d, предупреждений %d, отключено %d",
+		flood.Dropped, flood.Rejected, flood.Warned, flood.Kicked)
+
+	// Оставшиеся соединения, например просмотр повторов, закрываем сами
+	for _, conn := range conns {
+		conn.Close()
+	}
+	for _, conn := range conns {
+		if waitErr := conn.Wait(ctx); waitErr != nil {
+			return errors.Join(err, fmt.Errorf("соединения не закрыты: %w", waitErr))
+		}
+	}
+	return err
+}
+
+// handleMessages - единственный читатель соединения; запись идёт через Conn.
+// Сообщения разбираются по полю type обработчиками из commands
+func (s *WebSocketServer) handleMessages(conn *Conn, g *game.Game, userID uint) {
+	defer s.Rooms.LeaveRoom(context.Background(), g, userID, conn)
+
+	guard := newFloodGuard(s.Config, time.Now())
+	dispatch := &commandContext{game: g, conn: conn, userID: userID}
 	for {
-		var input game.PlayerInputData
-		if err := conn.ReadJSON(&input); err != nil {
-			if websocket.IsUnexpectedCloseError(err) {
-				log.Printf("Player %d disconnected: %v", userID, err)
+		var raw json.RawMessage
+		if err := conn.ReadJSON(&raw); err != nil {
+			logReadError("Player", userID, err)
+			return
+		}
+
+		now := time.Now()
+		cmd, err := dispatch.decode(raw)
+		action := guard.check(dispatch.reliable(cmd), now)
+		if action == floodAllow {
+			// Очередь комнаты не блокирует читателя: движение объединяется,
+			// а переполнение очереди команд считается нарушением
+			if err == nil {
+				err = dispatch.run(cmd)
+			}
+			var rejected *commandError
+			if errors.As(err, &rejected) {
+				dispatch.reject(rejected)
+				err = nil
+			}
+			if errors.Is(err, game.ErrCheatSuspected) {
+				// Отключённый античитом игрок не ждёт переподключения
+				log.Printf("Player %d: %v", userID, err)
+				s.Rooms.KickPlayer(context.Background(), g, userID)
+				conn.CloseWithReason(websocket.ClosePolicyViolation, "cheating suspected")
+				return
+			}
+			if err != nil {
+				// Сообщение дошло до комнаты, но не принято: оно не
+				// отброшено лимитом, однако засчитывается как нарушение
+				log.Printf("Player %d: %v", userID, err)
+				s.flood.rejected.Add(1)
+				action = guard.strike(now)
 			}
+		}
+		if !s.handleFlood(conn, g, userID, action, guard.strikes) {
 			return
 		}
+	}
+}
+
+// handleFlood применяет реакцию на флуд. Возвращает false, если клиента
+// нужно отключить: флудер, как и нарушитель античита, теряет место в
+// комнате и не может сразу вернуться
+func (s *WebSocketServer) handleFlood(conn *Conn, g *game.Game, userID uint, action floodAction, strikes int) bool {
+	switch action {
+	case floodDrop:
+		s.flood.dropped.Add(1)
+	case floodWarn:
+		s.flood.dropped.Add(1)
+		s.flood.warned.Add(1)
+		log.Printf("Player %d: превышен лимит сообщений (%d отброшено за %v), предупреждаем", userID, strikes, floodWindow)
+		conn.Send(protocol.TypeError, protocol.Error{
+			Code:    "rate_limited",
+			Message: "Too many messages, slow down",
+		})
+	case floodKick:
+		s.flood.kicked.Add(1)
+		log.Printf("Player %d: флуд не прекратился (%d нарушений за %v), отключаем", userID, strikes, floodWindow)
+		s.Rooms.KickPlayer(context.Background(), g, userID)
+		conn.CloseWithReason(websocket.ClosePolicyViolation, "rate limit exceeded")
+		return false
+	}
+	return true
+}
+
+// FloodStats возвращает счётчики защиты от флуда с запуска сервера
+func (s *WebSocketServer) FloodStats() FloodStats {
+	return s.flood.snapshot()
+}
 
-		s.Game.Inputs <- game.PlayerInput{
-			ID:    userID,
-			Input: input,
+// logReadError пишет в лог причину, по которой оборвалось чтение: молчание
+// клиента дольше ReadTimeout, слишком большое сообщение или неожиданный разрыв
+func logReadError(who string, id uint, err error) {
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		log.Printf("%s %d: соединение не отвечает, отключаем", who, id)
+	case errors.Is(err, websocket.ErrReadLimit):
+		log.Printf("%s %d: сообщение превышает лимит, отключаем", who, id)
+	case websocket.IsUnexpectedCloseError(err):
+		log.Printf("%s %d disconnected: %v", who, id, err)
+	}
+}
+
+func negotiate(versionParam, encoding string) (int, protocol.Codec, error) {
+	requested := 0
+	if versionParam != "" {
+		v, err := strconv.Atoi(versionParam)
+		if err != nil {
+			return 0, nil, errors.New("invalid protocol version")
 		}
+		requested = v
+	}
+
+	version, err := protocol.NegotiateVersion(requested)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	codec, err := protocol.NewCodec(encoding)
+	if err != nil {
+		return 0, nil, err
 	}
+	return version, codec, nil
 }
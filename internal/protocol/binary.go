@@ -0,0 +1,54 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// BinaryMarshaler реализуют нагрузки с собственным бинарным представлением
+type BinaryMarshaler interface {
+	MarshalBinaryTo(w *Writer)
+}
+
+// Writer накапливает little-endian представление сообщения
+type Writer struct {
+	buf []byte
+}
+
+func NewWriter(capacity int) *Writer {
+	return &Writer{buf: make([]byte, 0, capacity)}
+}
+
+func (w *Writer) Bytes() []byte { return w.buf }
+
+func (w *Writer) Uint8(v uint8) { w.buf = append(w.buf, v) }
+
+func (w *Writer) Bool(v bool) {
+	if v {
+		w.Uint8(1)
+	} else {
+		w.Uint8(0)
+	}
+}
+
+func (w *Writer) Uint16(v uint16) { w.buf = binary.LittleEndian.AppendUint16(w.buf, v) }
+
+func (w *Writer) Uint32(v uint32) { w.buf = binary.LittleEndian.AppendUint32(w.buf, v) }
+
+func (w *Writer) Uint64(v uint64) { w.buf = binary.LittleEndian.AppendUint64(w.buf, v) }
+
+func (w *Writer) Int32(v int32) { w.Uint32(uint32(v)) }
+
+// Float32 - координаты и статы не требуют двойной точности на проводе
+func (w *Writer) Float32(v float64) { w.Uint32(math.Float32bits(float32(v))) }
+
+// String пишет строку с длиной u16
+func (w *Writer) String(s string) {
+	if len(s) > math.MaxUint16 {
+		s = s[:math.MaxUint16]
+	}
+	w.Uint16(uint16(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *Writer) Raw(data []byte) { w.buf = append(w.buf, data...) }
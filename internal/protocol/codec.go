@@ -0,0 +1,83 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	EncodingJSON   = "json"
+	EncodingBinary = "binary"
+)
+
+// Codec кодирует конверт в кадр WebSocket
+type Codec interface {
+	Name() string
+	Binary() bool // Кадр отправляется как BinaryMessage, иначе TextMessage
+	Encode(env Envelope) ([]byte, error)
+}
+
+// NewCodec возвращает кодек по имени кодировки. Пустое имя - JSON
+func NewCodec(encoding string) (Codec, error) {
+	switch encoding {
+	case "", EncodingJSON:
+		return JSONCodec{}, nil
+	case EncodingBinary:
+		return BinaryCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// JSONCodec - читаемая кодировка для отладки
+type JSONCodec struct{}
+
+func (JSONCodec) Name() string { return EncodingJSON }
+
+func (JSONCodec) Binary() bool { return false }
+
+func (JSONCodec) Encode(env Envelope) ([]byte, error) {
+	return json.Marshal(env)
+}
+
+// Формат полезной нагрузки в бинарном кадре
+const (
+	payloadBinary uint8 = 0
+	payloadJSON   uint8 = 1
+)
+
+// BinaryCodec - компактная little-endian кодировка.
+// Кадр: version u8 | type u8 | seq u32 | format u8 | payload, где
+// version - версия, согласованная с клиентом при подключении.
+// Нагрузки, реализующие BinaryMarshaler, пишутся в бинарном виде,
+// остальные - как JSON, чтобы редкие сообщения не требовали своего формата
+type BinaryCodec struct{}
+
+func (BinaryCodec) Name() string { return EncodingBinary }
+
+func (BinaryCodec) Binary() bool { return true }
+
+func (BinaryCodec) Encode(env Envelope) ([]byte, error) {
+	version := env.Version
+	if version == 0 {
+		version = Version
+	}
+	w := NewWriter(64)
+	w.Uint8(uint8(version))
+	w.Uint8(uint8(env.Type))
+	w.Uint32(env.Seq)
+
+	if m, ok := env.Payload.(BinaryMarshaler); ok {
+		w.Uint8(payloadBinary)
+		m.MarshalBinaryTo(w)
+		return w.Bytes(), nil
+	}
+
+	data, err := json.Marshal(env.Payload)
+	if err != nil {
+		return nil, err
+	}
+	w.Uint8(payloadJSON)
+	w.Raw(data)
+	return w.Bytes(), nil
+}
@@ -0,0 +1,129 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	Version    = 1 // Текущая версия протокола сервера
+	MinVersion = 1 // Минимальная версия, которую сервер ещё поддерживает
+)
+
+// MessageType - тип исходящего сообщения в конверте
+type MessageType uint8
+
+const (
+	TypeWelcome MessageType = iota + 1
+	TypeError
+	TypeState
+	TypeLevelUp
+	TypeUpgrade
+	TypeInterest
+	TypeUpgradeRejected
+	TypeUpgradeCatalogue
+	TypeMatchEnd
+	TypeChat
+	TypePong
+	TypeEvents
+)
+
+var messageTypeNames = map[MessageType]string{
+	TypeWelcome:  "welcome",
+	TypeError:    "error",
+	TypeState:    "state",
+	TypeLevelUp:  "level_up",
+	TypeUpgrade:  "upgrade",
+	TypeInterest: "interest",
+
+	TypeUpgradeRejected:  "upgrade_rejected",
+	TypeUpgradeCatalogue: "upgrades",
+	TypeMatchEnd:         "match_end",
+	TypeChat:             "chat",
+	TypePong:             "pong",
+	TypeEvents:           "events",
+}
+
+func (t MessageType) String() string {
+	if name, ok := messageTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", uint8(t))
+}
+
+// Reliable сообщает, можно ли отбросить сообщение при переполнении очереди.
+// Снимки состояния устаревают каждый тик, остальные сообщения - события
+func (t MessageType) Reliable() bool {
+	return t != TypeState
+}
+
+func (t MessageType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.String())
+}
+
+// Envelope - общий конверт всех исходящих сообщений
+type Envelope struct {
+	Type    MessageType `json:"type"`
+	Seq     uint32      `json:"seq"`
+	Payload interface{} `json:"payload"`
+	Version int         `json:"-"` // Согласованная с клиентом версия, 0 - текущая
+}
+
+// Welcome отправляется сразу после подключения и фиксирует согласованный протокол
+type Welcome struct {
+	ProtocolVersion int    `json:"protocol_version"`
+	MinVersion      int    `json:"min_version"`
+	Encoding        string `json:"encoding"`
+	PlayerID        uint   `json:"player_id"`
+	Room            string `json:"room"`
+	Spectator       bool   `json:"spectator"`              // Подключение только для просмотра
+	ResumeToken     string `json:"resume_token,omitempty"` // Токен для возвращения в сессию после разрыва
+}
+
+func (w Welcome) MarshalBinaryTo(b *Writer) {
+	b.Uint8(uint8(w.ProtocolVersion))
+	b.Uint8(uint8(w.MinVersion))
+	b.String(w.Encoding)
+	b.Uint32(uint32(w.PlayerID))
+	b.String(w.Room)
+	b.Bool(w.Spectator)
+	b.String(w.ResumeToken)
+}
+
+// Error - сообщение об ошибке для клиента
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Command string `json:"command,omitempty"` // Тип входящего сообщения, вызвавшего ошибку
+	Seq     uint32 `json:"seq,omitempty"`     // Номер этого сообщения
+}
+
+func (e Error) MarshalBinaryTo(b *Writer) {
+	b.String(e.Code)
+	b.String(e.Message)
+	b.String(e.Command)
+	b.Uint32(e.Seq)
+}
+
+// Pong - ответ на ping клиента для оценки задержки и сдвига часов
+type Pong struct {
+	ClientTime uint64 `json:"t"`           // Время из ping, возвращается как есть
+	ServerTime int64  `json:"server_time"` // Unix-время сервера в миллисекундах
+}
+
+func (p Pong) MarshalBinaryTo(b *Writer) {
+	b.Uint64(p.ClientTime)
+	b.Uint64(uint64(p.ServerTime))
+}
+
+// NegotiateVersion выбирает версию протокола по запросу клиента.
+// requested == 0 означает "последняя поддерживаемая"
+func NegotiateVersion(requested int) (int, error) {
+	if requested == 0 {
+		return Version, nil
+	}
+	if requested < MinVersion || requested > Version {
+		return 0, fmt.Errorf("unsupported protocol version %d, supported %d..%d", requested, MinVersion, Version)
+	}
+	return requested, nil
+}
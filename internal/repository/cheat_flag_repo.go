@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"gameCore/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type CheatFlagRepo struct {
+	DB *gorm.DB
+}
+
+func NewCheatFlagRepo(db *gorm.DB) *CheatFlagRepo {
+	return &CheatFlagRepo{DB: db}
+}
+
+// Сохранение отметки античита для ручной проверки
+func (r *CheatFlagRepo) CreateCheatFlag(ctx context.Context, flag *models.CheatFlag) error {
+	return r.DB.WithContext(ctx).Create(flag).Error
+}
+
+// Получение всех отметок пользователя
+func (r *CheatFlagRepo) GetCheatFlagsByUser(ctx context.Context, userID uint) ([]models.CheatFlag, error) {
+	var flags []models.CheatFlag
+	err := r.DB.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Find(&flags).Error
+	if err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+// Получение отметок, которые ещё не проверены модератором
+func (r *CheatFlagRepo) GetUnreviewedCheatFlags(ctx context.Context) ([]models.CheatFlag, error) {
+	var flags []models.CheatFlag
+	err := r.DB.WithContext(ctx).
+		Where("reviewed = ?", false).
+		Order("created_at ASC").
+		Find(&flags).Error
+	if err != nil {
+		return nil, err
+	}
+	return flags, nil
+}
+
+type CheatFlagRepository interface {
+	CreateCheatFlag(ctx context.Context, flag *models.CheatFlag) error
+	GetCheatFlagsByUser(ctx context.Context, userID uint) ([]models.CheatFlag, error)
+	GetUnreviewedCheatFlags(ctx context.Context) ([]models.CheatFlag, error)
+}
@@ -42,6 +42,14 @@ func (r *PlayerRepo) GetPlayer(ctx context.Context, userID uint) (*models.Player
 	return &player, nil
 }
 
+// Сохранение итогов матча одной пачкой
+func (r *PlayerRepo) SaveResults(ctx context.Context, players []models.Player) error {
+	if len(players) == 0 {
+		return nil
+	}
+	return r.DB.WithContext(ctx).Create(&players).Error
+}
+
 // Получение всех игроков в сессии
 func (r *PlayerRepo) GetPlayersBySession(ctx context.Context, gameSessionID uint) ([]models.Player, error) {
 	var players []models.Player
@@ -55,8 +63,9 @@ func (r *PlayerRepo) GetPlayersBySession(ctx context.Context, gameSessionID uint
 }
 
 type PlayerRepository interface {
-	PlayerExists(ctx context.Context, userID uint) (bool, error)
+	PlayerExists(ctx context.Context, userID uint, gameSessionID uint) (bool, error)
 	CreatePlayer(ctx context.Context, player *models.Player) error
+	SaveResults(ctx context.Context, players []models.Player) error
 	GetPlayer(ctx context.Context, userID uint) (*models.Player, error)
 	GetPlayersBySession(ctx context.Context, gameSessionID uint) ([]models.Player, error)
 }
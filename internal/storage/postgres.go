@@ -74,6 +74,7 @@ func InitTables() error {
 		&models.Matchmaking{},
 		&models.ChatMessage{},
 		&models.Player{},
+		&models.CheatFlag{},
 	)
 	if err != nil {
 		return fmt.Errorf("error migrating database: %w", err)
@@ -32,3 +32,14 @@ func InitRedis(cfg config.RedisConfig) error {
 	fmt.Println("✅ Redis connection established")
 	return nil
 }
+
+// Graceful shutdown
+func CloseRedis() error {
+	if RedisClient != nil {
+		if err := RedisClient.Close(); err != nil {
+			return fmt.Errorf("failed to close redis: %w", err)
+		}
+		fmt.Println("🧰 Redis connection closed")
+	}
+	return nil
+}
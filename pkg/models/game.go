@@ -12,6 +12,7 @@ type Player struct {
 	UserID        uint `gorm:"not null"`
 	GameSessionID uint `gorm:"not null"`
 	Score         int  `gorm:"default:0"` // score in currect match
+	Level         int  `gorm:"default:1"` // level reached in the match
 	IsReady       bool `gorm:"default:false"`
 }
 
@@ -22,6 +23,7 @@ type GameSession struct {
 	StartTime  time.Time `gorm:"not null"`
 	EndTime    time.Time
 	MaxPlayers int      `gorm:"not null"`
+	Seed       int64    `gorm:"not null;default:0"` // seed of the match simulation
 	Players    []Player `gorm:"foreignKey:GameSessionID"`
 }
 
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Account flagged by the anti-cheat for manual review
+type CheatFlag struct {
+	gorm.Model
+	UserID        uint    `gorm:"not null;index"`
+	GameSessionID uint    `gorm:"not null"`
+	Score         float64 `gorm:"not null"`           // suspicion score at the moment of flagging
+	Reasons       string  `gorm:"type:text;not null"` // detected patterns, e.g. "aim_snap=3,fire_pattern=1"
+	Kicked        bool    `gorm:"default:false"`      // player was removed from the match
+	Reviewed      bool    `gorm:"default:false"`
+}
@@ -36,7 +36,7 @@ export default function UpgradesMenu({ skillPoints, onUpgrade }) {
                 </button>
                 <button
                     className={styles.upgradeBtn}
-                    onClick={() => onUpgrade('movement')}
+                    onClick={() => onUpgrade('speed')}
                     disabled={skillPoints <= 0}
                 >
                     Увеличить скорость
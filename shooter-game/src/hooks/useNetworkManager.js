@@ -1,7 +1,15 @@
 import { useCallback, useRef } from 'react';
+import { createSnapshotStore } from '../utils/snapshot';
+
+// Как часто подтверждать снимки, если игрок ничего не вводит
+const ACK_INTERVAL_MS = 100;
 
 export default function useNetworkManager({ setGameState }) {
     const socketRef = useRef(null);
+    const lastSnapshotRef = useRef(0);
+    const lastTickRef = useRef(0);
+    const inputSeqRef = useRef(0);
+    const lastSentRef = useRef(0);
 
     const connect = useCallback((token) => {
         if (!token) {
@@ -13,7 +21,14 @@ export default function useNetworkManager({ setGameState }) {
             socketRef.current.close();
         }
 
-        const socket = new WebSocket(`ws://localhost:8080/api/ws?token=${encodeURIComponent(token)}`);
+        const applySnapshot = createSnapshotStore();
+        lastSnapshotRef.current = 0;
+        inputSeqRef.current = 0;
+
+        // Токен возобновления позволяет вернуться в свою сессию после разрыва
+        const resume = sessionStorage.getItem('resumeToken');
+        const resumeParam = resume ? `&resume=${encodeURIComponent(resume)}` : '';
+        const socket = new WebSocket(`ws://localhost:8080/api/ws?token=${encodeURIComponent(token)}&protocol=1&encoding=json${resumeParam}`);
         socketRef.current = socket;
 
         socket.onopen = () => {
@@ -23,8 +38,45 @@ export default function useNetworkManager({ setGameState }) {
 
         socket.onmessage = (event) => {
             try {
-                const data = JSON.parse(event.data);
-                setGameState(prev => ({ ...prev, ...data }));
+                // Сервер присылает конверт {type, seq, payload}
+                const { type, payload } = JSON.parse(event.data);
+                switch (type) {
+                    case 'welcome':
+                        if (payload.resume_token) {
+                            sessionStorage.setItem('resumeToken', payload.resume_token);
+                        }
+                        setGameState(prev => ({ ...prev, myPlayerId: payload.player_id, room: payload.room }));
+                        break;
+                    case 'state': {
+                        const world = applySnapshot(payload);
+                        if (!world) break;
+                        lastSnapshotRef.current = payload.id;
+                        lastTickRef.current = payload.tick;
+                        // Без ввода сервер не узнаёт о полученных снимках - подтверждаем сами
+                        if (Date.now() - lastSentRef.current > ACK_INTERVAL_MS) {
+                            lastSentRef.current = Date.now();
+                            socket.send(JSON.stringify({ type: 'ack', ack: payload.id, view_tick: payload.tick }));
+                        }
+                        setGameState(prev => ({
+                            ...prev,
+                            players: world.players,
+                            bullets: Object.values(world.bullets),
+                            objects: Object.values(world.objects),
+                        }));
+                        break;
+                    }
+                    case 'upgrades':
+                        setGameState(prev => ({ ...prev, upgradeCatalogue: payload.upgrades }));
+                        break;
+                    case 'upgrade_rejected':
+                        console.warn('Upgrade rejected:', payload.upgrade, payload.reason);
+                        break;
+                    case 'error':
+                        console.error('Server error:', payload.code, payload.message, payload.command || '');
+                        break;
+                    default:
+                        setGameState(prev => ({ ...prev, ...payload }));
+                }
             } catch (e) {
                 console.error('WebSocket message error:', e);
             }
@@ -43,7 +95,17 @@ export default function useNetworkManager({ setGameState }) {
 
     const send = useCallback((data) => {
         if (socketRef.current?.readyState === WebSocket.OPEN) {
-            socketRef.current.send(JSON.stringify(data));
+            // Каждое сообщение подтверждает последний полученный снимок и
+            // сообщает тик, который видел игрок, для компенсации лага.
+            // Номер ввода возвращается в last_seq для согласования предсказания
+            inputSeqRef.current += 1;
+            lastSentRef.current = Date.now();
+            socketRef.current.send(JSON.stringify({
+                ...data,
+                seq: inputSeqRef.current,
+                ack: lastSnapshotRef.current,
+                view_tick: lastTickRef.current,
+            }));
         }
     }, []);
 
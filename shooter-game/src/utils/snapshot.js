@@ -0,0 +1,54 @@
+// Восстановление мира из дельта-снимков сервера.
+// Ключевой кадр (baseline === 0) заменяет мир целиком, иначе дельта
+// применяется к ранее полученному снимку с id === baseline.
+const HISTORY_SIZE = 32;
+
+const emptyWorld = () => ({ players: {}, bullets: {}, objects: {} });
+
+function applyEntities(target, deltas, removed) {
+    for (const delta of deltas || []) {
+        const prev = target[delta.id] || {};
+        const next = { ...prev, ...delta };
+        if (delta.stats) {
+            next.stats = { ...(prev.stats || {}), ...delta.stats };
+        }
+        target[delta.id] = next;
+    }
+    for (const id of removed || []) {
+        delete target[id];
+    }
+}
+
+export function createSnapshotStore() {
+    const history = new Map();
+
+    return function applySnapshot(msg) {
+        let base = emptyWorld();
+        if (msg.baseline !== 0) {
+            const known = history.get(msg.baseline);
+            if (!known) {
+                // База уже забыта - ждём следующий ключевой кадр
+                return null;
+            }
+            base = known;
+        }
+
+        const world = {
+            players: { ...base.players },
+            bullets: { ...base.bullets },
+            objects: { ...base.objects },
+        };
+        const removed = msg.removed || {};
+        applyEntities(world.players, msg.players, removed.players);
+        applyEntities(world.bullets, msg.bullets, removed.bullets);
+        applyEntities(world.objects, msg.objects, removed.objects);
+
+        history.set(msg.id, world);
+        for (const id of history.keys()) {
+            if (id <= msg.id - HISTORY_SIZE) {
+                history.delete(id);
+            }
+        }
+        return world;
+    };
+}